@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +16,17 @@ var Args struct {
 	directory  os.FileInfo
 	positional []string
 	outputFile string
+	outputMode OutputMode
+	withData   bool
+	minify     bool
+	mangle     bool
+	release    bool
+	maxSize    int
+	entities   string
+	fontFirst  int
+	fontLast   int
+	fontBase   int
+	fontColor  int
 	defines    map[string]string
 	watchMode  bool
 }
@@ -22,23 +34,53 @@ var Args struct {
 // Must be called as soon as the program starts to initialize Args
 func getArguments() {
 	// define pointers to the arguments which will be filled up when flag.Parse() is called
-	langFlag := flag.String("l", string(auto), "Which language to use. Args are: lua | wren | moon | auto")
+	langFlag := flag.String("l", string(auto), "Which language to use. Args are: lua | wren | moon | js | fnl | nut | rb | py | auto")
 	dirFlag := flag.String("d", ".", "The directory containing the main file and the subfiles")
-	outFlag := flag.String("o", "out", "The output file (sans extension)")
+	outFlag := flag.String("o", "out", "The output file (sans extension). Give it a .tic extension to write the code into a cartridge instead, or .png for a cartridge that can be shared as an image")
 	watchFlag := flag.Bool("w", false, "Whether to enable Watch mode, which automatically recompiles if a file has changed in the directory")
 	definesFlag := flag.String("D", "", "Used to pass in defines before compiling. Format is -D \"var1=value;var2=value;var3=value\"")
+	entitiesFlag := flag.String("entities", "", "The name of a table to generate from the objects of the Tiled map (map.tmj or map.tmx), holding the name, x, y and properties of each one. In ruby, the name must start with a capital letter to be visible inside methods")
+	fontRangeFlag := flag.String("font-range", "32-127", "The range of character codes to draw from the bitmap font (font.bdf) into the sprites")
+	fontBaseFlag := flag.Int("font-base", 256, "The sprite that character code 0 of the bitmap font maps to. The font() function of the TIC-80 expects 256")
+	fontColorFlag := flag.Int("font-color", 12, "The palette index to draw the glyphs of the bitmap font in. The rest of their sprites is color 0")
+	minifyFlag := flag.Bool("minify", false, "Whether to minify the compiled code (everything but the prelude) so that more of it fits in a cartridge. Only supported in moonscript and wren for now")
+	mangleFlag := flag.Bool("mangle", false, "Whether to also rename local variables, parameters and top level symbols that are not exported to shorter names. Implies -minify")
+	releaseFlag := flag.Bool("release", false, "Whether to build for release, leaving out the top level declarations that are exported but never imported or used")
+	maxSizeFlag := flag.Int("max-size", 0, "The most bytes of code the build may produce before it fails, counting the prelude. 0 means no limit. The TIC-80 runs up to 65536")
+	dataFlag := flag.Bool("data", false, "Whether to append the TIC-80 data sections (<TILES>, <MAP>, <SFX>...) to a text output file, making it a complete cartridge. Without it, a text output file is plain code, and the assets of the project (along with the code generated from them) are left out")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  ticc [flags]                           compile the project in a directory\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  ticc extract <cart.tic> [-d outdir]    split a cartridge into a project directory\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  ticc inspect <cart.tic>                list the chunks of a cartridge and check it for problems\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+		flag.PrintDefaults()
+	}
 
 	// begin parsing the flags
 	flag.Parse()
 
+	Args.withData = *dataFlag
+	Args.minify = *minifyFlag || *mangleFlag
+	Args.mangle = *mangleFlag
+	Args.release = *releaseFlag
+	Args.maxSize = *maxSizeFlag
+
+	if Args.maxSize < 0 {
+		checkError(errors.New("-max-size can't be negative"))
+	}
+
 	// these setup functions have to be performed in this particular order
 	// because they depend on certain fields of Args to be set when they are called
 	_setDir(*dirFlag)
 	_setLanguage(*langFlag)
 	_setOutputFile(*outFlag)
 	_setDefines(*definesFlag)
+	_setFont(*fontRangeFlag, *fontBaseFlag, *fontColorFlag)
 
 	Args.watchMode = *watchFlag
+	Args.entities = *entitiesFlag
 
 	// this gives all the non-flag command line args
 	Args.positional = flag.Args()
@@ -69,7 +111,7 @@ func _setLanguage(rawInput string) {
 	}
 
 	if !isSupportedLanguage(Args.language) {
-		checkError(fmt.Errorf("invalid language detected (%s) the supported languages are: lua | moon | wren", Args.language))
+		checkError(fmt.Errorf("invalid language detected (%s) the supported languages are: lua | moon | wren | js | fnl | nut | rb | py", Args.language))
 	}
 }
 
@@ -80,16 +122,26 @@ func _setOutputFile(filename string) {
 	if ext == "" {
 		// auto fix
 		filename += "." + string(Args.language)
-	} else if ext != string(Args.language) {
+		Args.outputMode = outputText
+	} else if ext == ".tic" {
+		Args.outputMode = outputCart
+	} else if ext == ".png" {
+		Args.outputMode = outputPNG
+	} else if ext == "."+string(Args.language) {
+		Args.outputMode = outputText
+	} else {
 		checkError(
 			errors.New(
-				`The output file must have the same extension as the detected language. 
+				`The output file must have the same extension as the detected language, or be a .tic or .png cartridge. 
 				Alternatively, you may omit the extension and it will automatically be detected`,
 			),
 		)
 	}
-	// check to see if the output file already exists, and if so, delete it
-	_deleteIfExists(filename)
+	// check to see if the output file already exists, and if so, delete it.
+	// cartridges are kept around since the compiled code only replaces the code inside of them
+	if Args.outputMode == outputText && !Args.withData {
+		_deleteIfExists(filename)
+	}
 	Args.outputFile = filename
 }
 
@@ -117,6 +169,30 @@ func _setDefines(input string) {
 	}
 }
 
+func _setFont(codeRange string, base int, color int) {
+	bounds := strings.Split(codeRange, "-")
+
+	first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	last := first
+
+	if err == nil && len(bounds) == 2 {
+		last, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+	}
+
+	if err != nil || len(bounds) > 2 || first < 0 || last < first {
+		checkError(fmt.Errorf("invalid -font-range (%s) expected a range of character codes like 32-127", codeRange))
+	}
+
+	if color < 0 || color > 15 {
+		checkError(fmt.Errorf("invalid -font-color (%d) expected a palette index from 0 to 15", color))
+	}
+
+	Args.fontFirst = first
+	Args.fontLast = last
+	Args.fontBase = base
+	Args.fontColor = color
+}
+
 func _deleteIfExists(filename string) {
 	// does it exist?
 	_, err := os.Stat(filename)
@@ -0,0 +1,100 @@
+package assets
+
+import (
+	"fmt"
+	"image"
+	// the cover can be a GIF recorded in the TIC-80 as well as a PNG
+	_ "image/gif"
+	"os"
+)
+
+const (
+	// ScreenWidth is the width of the TIC-80 screen in pixels
+	ScreenWidth = 240
+	// ScreenHeight is the height of the TIC-80 screen in pixels
+	ScreenHeight = 136
+)
+
+// ImportCover reads a PNG or GIF image (the first frame, if it is animated) and converts it into the data of a SCREEN
+// chunk, which is the cover image of the cartridge. The image must be 240x136, or a whole multiple of it like the
+// screenshots the TIC-80 saves at a larger scale, in which case it is scaled down. Every pixel becomes the closest
+// color of the palette.
+func ImportCover(filename string, palette Palette) ([]byte, error) {
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	scale := bounds.Dx() / ScreenWidth
+
+	if scale == 0 || bounds.Dx() != ScreenWidth*scale || bounds.Dy() != ScreenHeight*scale {
+		return nil, fmt.Errorf("cover is %dx%d, but it must be %dx%d or a whole multiple of it", bounds.Dx(), bounds.Dy(), ScreenWidth, ScreenHeight)
+	}
+
+	if scale > 1 {
+		img = _scaleDown(img, scale)
+	}
+
+	pixels := quantizeNearest(img, palette)
+	screen := make([]byte, ScreenWidth*ScreenHeight/2)
+
+	for i, index := range pixels {
+		// two pixels to a byte, with the left one in the low nibble just like in the tiles
+		if i%2 == 0 {
+			screen[i/2] |= index & 0x0F
+		} else {
+			screen[i/2] |= index << 4
+		}
+	}
+
+	return screen, nil
+}
+
+// _scaleDown shrinks the image by a whole factor, taking the top left pixel of every block of pixels. Scaled up
+// screenshots have blocks of a single color, so nothing is lost.
+func _scaleDown(img image.Image, scale int) image.Image {
+	bounds := img.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, bounds.Dx()/scale, bounds.Dy()/scale))
+
+	for y := 0; y < scaled.Bounds().Dy(); y++ {
+		for x := 0; x < scaled.Bounds().Dx(); x++ {
+			scaled.Set(x, y, img.At(bounds.Min.X+x*scale, bounds.Min.Y+y*scale))
+		}
+	}
+
+	return scaled
+}
+
+// ExportCover writes the data of a SCREEN chunk as a 240x136 PNG image, paletted with the colors of the palette in
+// order so that importing it again gives back exactly the same data
+func ExportCover(filename string, screen []byte, palette Palette) error {
+	return writePNG(filename, CoverImage(screen, palette))
+}
+
+// CoverImage draws the data of a SCREEN chunk as a 240x136 image, paletted with the colors of the palette in order
+func CoverImage(screen []byte, palette Palette) *image.Paletted {
+	// the chunk may have been trimmed of trailing zeroes
+	data := make([]byte, ScreenWidth*ScreenHeight/2)
+	copy(data, screen)
+
+	img := image.NewPaletted(image.Rect(0, 0, ScreenWidth, ScreenHeight), palette.Colors())
+
+	for i := range img.Pix {
+		if i%2 == 0 {
+			img.Pix[i] = data[i/2] & 0x0F
+		} else {
+			img.Pix[i] = data[i/2] >> 4
+		}
+	}
+
+	return img
+}
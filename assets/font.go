@@ -0,0 +1,223 @@
+package assets
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/novemberisms/ticc/cart"
+)
+
+// SpriteCount is the number of sprites in the two sprite banks, the tiles (0 to 255) and the sprites (256 to 511)
+const SpriteCount = 2 * SheetTiles * SheetTiles
+
+// A Glyph is a character of a bitmap font, drawn into a single 8x8 sprite
+type Glyph struct {
+	// Code is the character code of the glyph
+	Code int
+	// Width is how far the next character is moved along after this one, in pixels
+	Width int
+	// Pixels holds whether each pixel of the sprite is set, row by row
+	Pixels [TileSize * TileSize]bool
+}
+
+// ImportBDF reads the glyphs of the characters from first to last (inclusive) out of a BDF bitmap font. Every glyph is
+// placed in its 8x8 sprite so that the baseline is the same for all of them, and an error is returned if any of them
+// does not fit. Characters that the font has no glyph for are left out.
+func ImportBDF(filename string, first int, last int) ([]Glyph, error) {
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	glyphs := []Glyph{}
+	// the ascent is how far the top of the sprite is above the baseline
+	ascent := -1
+	var boundingAscent int
+
+	var glyph *Glyph
+	var width, height, xOffset, yOffset int
+	bitmapRow := -1
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		// reads the numbers after the keyword of the line
+		numbers := func(count int) ([]int, error) {
+			if len(fields) < count+1 {
+				return nil, fmt.Errorf("%s needs %d numbers (line %d)", fields[0], count, lineNumber)
+			}
+			values := make([]int, count)
+			for i := range values {
+				value, err := strconv.Atoi(fields[i+1])
+				if err != nil {
+					return nil, fmt.Errorf("'%s' is not a number (line %d)", fields[i+1], lineNumber)
+				}
+				values[i] = value
+			}
+			return values, nil
+		}
+
+		if bitmapRow >= 0 {
+			if fields[0] == "ENDCHAR" {
+				bitmapRow = -1
+				if glyph != nil {
+					glyphs = append(glyphs, *glyph)
+				}
+				continue
+			}
+			if glyph != nil {
+				if err := _drawBitmapRow(glyph, fields[0], bitmapRow, width, height, xOffset, yOffset, ascent); err != nil {
+					return nil, fmt.Errorf("%w (line %d)", err, lineNumber)
+				}
+			}
+			bitmapRow++
+			continue
+		}
+
+		switch fields[0] {
+		case "FONTBOUNDINGBOX":
+			values, err := numbers(4)
+			if err != nil {
+				return nil, err
+			}
+			boundingAscent = values[1] + values[3]
+		case "FONT_ASCENT":
+			values, err := numbers(1)
+			if err != nil {
+				return nil, err
+			}
+			ascent = values[0]
+		case "STARTCHAR":
+			glyph = nil
+			width, height, xOffset, yOffset = 0, 0, 0, 0
+		case "ENCODING":
+			values, err := numbers(1)
+			if err != nil {
+				return nil, err
+			}
+			if values[0] >= first && values[0] <= last {
+				glyph = &Glyph{Code: values[0]}
+			}
+		case "DWIDTH":
+			values, err := numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			if glyph != nil {
+				glyph.Width = values[0]
+			}
+		case "BBX":
+			values, err := numbers(4)
+			if err != nil {
+				return nil, err
+			}
+			width, height, xOffset, yOffset = values[0], values[1], values[2], values[3]
+		case "BITMAP":
+			if ascent < 0 {
+				// fonts without the FONT_ASCENT property are aligned to their bounding box
+				ascent = boundingAscent
+			}
+			bitmapRow = 0
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if bitmapRow >= 0 {
+		return nil, errors.New("the font ends in the middle of a glyph")
+	}
+
+	return glyphs, nil
+}
+
+// _drawBitmapRow sets the pixels of a row of the bitmap of a glyph, which is given in hex with the leftmost pixel in
+// the highest bit
+func _drawBitmapRow(glyph *Glyph, hexRow string, row int, width int, height int, xOffset int, yOffset int, ascent int) error {
+	if row >= height {
+		return fmt.Errorf("character %d has more rows than its BBX", glyph.Code)
+	}
+
+	bits, err := strconv.ParseUint(hexRow, 16, 64)
+
+	if err != nil || len(hexRow) > 16 {
+		return fmt.Errorf("'%s' is not a row of a bitmap", hexRow)
+	}
+
+	// the bottom of the bounding box is yOffset pixels above the baseline
+	y := ascent - (yOffset + height) + row
+	totalBits := uint(len(hexRow) * 4)
+
+	for column := 0; column < width; column++ {
+		if column >= int(totalBits) || bits&(1<<(totalBits-1-uint(column))) == 0 {
+			continue
+		}
+
+		x := xOffset + column
+
+		if x < 0 || x >= TileSize || y < 0 || y >= TileSize {
+			return fmt.Errorf("character %d does not fit in an %dx%d sprite", glyph.Code, TileSize, TileSize)
+		}
+
+		glyph.Pixels[y*TileSize+x] = true
+	}
+
+	return nil
+}
+
+// SetCartGlyphs draws every glyph into the sprite at base plus its character code, the way the font() function of the
+// TIC-80 finds them when base is 256. The set pixels are drawn in the given color and the others in color 0.
+func SetCartGlyphs(tic *cart.Cart, glyphs []Glyph, base int, color byte) error {
+	sheets := make(map[cart.ChunkType][]byte)
+
+	for _, glyph := range glyphs {
+		sprite := base + glyph.Code
+
+		if sprite < 0 || sprite >= SpriteCount {
+			return fmt.Errorf("character %d would be drawn into sprite %d, but there are only %d sprites", glyph.Code, sprite, SpriteCount)
+		}
+
+		chunkType := cart.ChunkTiles
+		if sprite >= SheetTiles*SheetTiles {
+			chunkType = cart.ChunkSprites
+		}
+
+		sheet, ok := sheets[chunkType]
+		if !ok {
+			// the chunk may have been trimmed of trailing zeroes
+			sheet = make([]byte, SheetTiles*SheetTiles*TileBytes)
+			if chunk := tic.Chunk(chunkType, 0); chunk != nil {
+				copy(sheet, chunk.Data)
+			}
+			sheets[chunkType] = sheet
+		}
+
+		tile := sheet[(sprite%(SheetTiles*SheetTiles))*TileBytes:]
+		for i, set := range glyph.Pixels {
+			pixel := byte(0)
+			if set {
+				pixel = color
+			}
+			setTilePixel(tile, i%TileSize, i/TileSize, pixel)
+		}
+	}
+
+	for chunkType, sheet := range sheets {
+		tic.SetChunk(chunkType, 0, cart.TrimTrailingZeros(sheet))
+	}
+
+	return nil
+}
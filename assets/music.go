@@ -0,0 +1,306 @@
+package assets
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/novemberisms/ticc/cart"
+)
+
+const (
+	// MusicTracks is the number of tracks (songs) in a TIC-80 cartridge
+	MusicTracks = 8
+	// MusicFrames is the number of frames in every track, each playing one pattern on every channel
+	MusicFrames = 16
+	// MusicChannels is the number of channels that play at the same time
+	MusicChannels = 4
+	// MusicPatterns is the number of patterns in a TIC-80 cartridge, which are numbered from 1 like in its tracker
+	MusicPatterns = 60
+	// PatternRows is the number of rows in every pattern
+	PatternRows = 64
+
+	// every frame packs the 6 bit pattern numbers of the 4 channels into 3 bytes, and the tempo, rows and speed of
+	// the track follow its frames
+	frameSize = 3
+	trackSize = MusicFrames*frameSize + 3
+	// every row of a pattern takes 3 bytes
+	patternSize = PatternRows * 3
+
+	// the tempo, rows and speed of a track are stored as the difference from these defaults
+	defaultTempo = 150
+	defaultSpeed = 6
+
+	// the notes in a pattern start at 4, after the values for no note and for stopping the note
+	noteStop  = 1
+	noteStart = 4
+)
+
+// musicCommands are the letters of the effect commands of the tracker, in the order the TIC-80 numbers them
+const musicCommands = "-MCJSPVD"
+
+// matches an effect command like 'M8A', which is its letter followed by its 2 hex parameters
+var reMusicCommand = regexp.MustCompile(`^([MCJSPVD])([0-9a-fA-F])([0-9a-fA-F])$`)
+
+// Music holds the tracks and patterns read from a text file, already in the format of the MUSIC and PATTERNS chunks
+type Music struct {
+	// Tracks holds the data of every track in the file by its index
+	Tracks map[int][]byte
+	// TrackNames holds the optional names given to the tracks by their index
+	TrackNames map[int]string
+	// Patterns holds the data of every pattern in the file by its number, from 1 to 60
+	Patterns map[int][]byte
+}
+
+// ImportMusic reads a text file of tracks and patterns. Every track starts with 'track', its index from 0 to 7 and an
+// optional name, and every pattern with 'pattern' and its number from 1 to 60. Comments start with a '#' at the
+// start of a line or after a space, as in ImportSFX:
+//
+//	track 0 theme
+//	  tempo 150                # from 40 to 250
+//	  speed 6                  # from 1 to 31
+//	  rows  64                 # the rows of every pattern that are played, from 1 to 64
+//	  frame 0  1  2 -- --      # the pattern of each of the 4 channels in the frame, or -- for none
+//	  frame 1  1  3 -- --
+//
+//	pattern 1
+//	  0  C-4 2                 # row, note and sfx
+//	  4  E-4 2 M8A             # an effect command and its 2 parameters can follow
+//	  8  off                   # stops the note
+//	  12 ... V24               # a command on its own
+//
+// Rows and frames that are left out are empty. Tracks and patterns that are not in the file are left as they are.
+func ImportMusic(filename string) (*Music, error) {
+	music := &Music{
+		Tracks:     make(map[int][]byte),
+		TrackNames: make(map[int]string),
+		Patterns:   make(map[int][]byte),
+	}
+
+	var track, pattern []byte
+	var seenFrames, seenRows map[int]bool
+	var seenSettings map[string]bool
+
+	err := _scanLines(filename, func(lineNumber int, line string) error {
+		fields := strings.Fields(line)
+		keyword, values := strings.ToLower(fields[0]), fields[1:]
+
+		// wraps an error with the line it was found on
+		lineError := func(err error) error {
+			if err == nil {
+				return nil
+			}
+			return fmt.Errorf("%w (line %d)", err, lineNumber)
+		}
+
+		switch keyword {
+		case "track":
+			if len(values) < 1 || len(values) > 2 {
+				return fmt.Errorf("expected 'track index [name]' (line %d): %s", lineNumber, line)
+			}
+			index, err := _parseRanged(values[0], 0, MusicTracks-1, "track index")
+			if err != nil {
+				return lineError(err)
+			}
+			if _, duplicate := music.Tracks[index]; duplicate {
+				return fmt.Errorf("track %d is defined more than once (line %d)", index, lineNumber)
+			}
+			track, pattern = make([]byte, trackSize), nil
+			seenFrames, seenSettings = make(map[int]bool), make(map[string]bool)
+			music.Tracks[index] = track
+			if len(values) == 2 {
+				music.TrackNames[index] = values[1]
+			}
+			return nil
+		case "pattern":
+			if len(values) != 1 {
+				return fmt.Errorf("expected 'pattern number' (line %d): %s", lineNumber, line)
+			}
+			number, err := _parseRanged(values[0], 1, MusicPatterns, "pattern number")
+			if err != nil {
+				return lineError(err)
+			}
+			if _, duplicate := music.Patterns[number]; duplicate {
+				return fmt.Errorf("pattern %d is defined more than once (line %d)", number, lineNumber)
+			}
+			track, pattern = nil, make([]byte, patternSize)
+			seenRows = make(map[int]bool)
+			music.Patterns[number] = pattern
+			return nil
+		}
+
+		switch {
+		case track != nil:
+			if seenSettings[keyword] && keyword != "frame" {
+				return fmt.Errorf("the %s of the track is given more than once (line %d)", keyword, lineNumber)
+			}
+			seenSettings[keyword] = true
+			return lineError(_parseTrackSetting(track, keyword, values, seenFrames))
+		case pattern != nil:
+			return lineError(_parsePatternRow(pattern, fields, seenRows))
+		default:
+			return fmt.Errorf("expected 'track' or 'pattern' before anything else (line %d): %s", lineNumber, line)
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return music, nil
+}
+
+// _parseTrackSetting writes a line of a track into its data
+func _parseTrackSetting(track []byte, keyword string, values []string, seenFrames map[int]bool) error {
+	settings := track[MusicFrames*frameSize:]
+
+	if keyword == "frame" {
+		if len(values) != 1+MusicChannels {
+			return fmt.Errorf("expected 'frame index' followed by the patterns of the %d channels", MusicChannels)
+		}
+		index, err := _parseRanged(values[0], 0, MusicFrames-1, "frame index")
+		if err != nil {
+			return err
+		}
+		if seenFrames[index] {
+			return fmt.Errorf("frame %d is given more than once", index)
+		}
+		seenFrames[index] = true
+
+		packed := 0
+		for channel, value := range values[1:] {
+			number := 0
+			if value != "--" {
+				if number, err = _parseRanged(value, 1, MusicPatterns, "pattern number"); err != nil {
+					return err
+				}
+			}
+			packed |= number << (channel * 6)
+		}
+		for i := 0; i < frameSize; i++ {
+			track[index*frameSize+i] = byte(packed >> (i * 8))
+		}
+		return nil
+	}
+
+	if len(values) != 1 {
+		return fmt.Errorf("expected '%s' and a number", keyword)
+	}
+
+	switch keyword {
+	case "tempo":
+		tempo, err := _parseRanged(values[0], 40, 250, "tempo")
+		if err != nil {
+			return err
+		}
+		settings[0] = byte(int8(tempo - defaultTempo))
+	case "rows":
+		rows, err := _parseRanged(values[0], 1, PatternRows, "number of rows")
+		if err != nil {
+			return err
+		}
+		settings[1] = byte(PatternRows - rows)
+	case "speed":
+		speed, err := _parseRanged(values[0], 1, 31, "speed")
+		if err != nil {
+			return err
+		}
+		settings[2] = byte(int8(speed - defaultSpeed))
+	default:
+		return fmt.Errorf("unknown track setting '%s'", keyword)
+	}
+
+	return nil
+}
+
+// _parsePatternRow writes a row of a pattern into its data. The fields are the row, the note (or 'off' or '...'), the
+// sfx if there is a note, and an optional command.
+func _parsePatternRow(pattern []byte, fields []string, seenRows map[int]bool) error {
+	row, err := _parseRanged(fields[0], 0, PatternRows-1, "row")
+	if err != nil {
+		return err
+	}
+
+	if seenRows[row] {
+		return fmt.Errorf("row %d is given more than once", row)
+	}
+	seenRows[row] = true
+
+	if len(fields) < 2 {
+		return fmt.Errorf("row %d has no note", row)
+	}
+
+	rest := fields[2:]
+	note, octave, sfx := 0, 0, 0
+
+	switch strings.ToLower(fields[1]) {
+	case "...":
+	case "off":
+		note = noteStop
+	default:
+		if note, octave, err = ParseNote(fields[1]); err != nil {
+			return err
+		}
+		note += noteStart
+
+		if len(rest) == 0 {
+			return fmt.Errorf("the note of row %d has no sfx", row)
+		}
+		if sfx, err = _parseRanged(rest[0], 0, SFXCount-1, "sfx"); err != nil {
+			return err
+		}
+		rest = rest[1:]
+	}
+
+	command, param1, param2 := 0, 0, 0
+
+	if len(rest) > 0 {
+		matchInfo := reMusicCommand.FindStringSubmatch(strings.ToUpper(rest[0]))
+		if matchInfo == nil {
+			return fmt.Errorf("'%s' is not a command like M8A, which is one of %s followed by 2 hex digits", rest[0], musicCommands[1:])
+		}
+		command = strings.Index(musicCommands, matchInfo[1])
+		p1, _ := strconv.ParseUint(matchInfo[2], 16, 4)
+		p2, _ := strconv.ParseUint(matchInfo[3], 16, 4)
+		param1, param2 = int(p1), int(p2)
+		rest = rest[1:]
+	}
+
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected '%s' at the end of row %d", strings.Join(rest, " "), row)
+	}
+
+	data := pattern[row*3:]
+	data[0] = byte(note | param1<<4)
+	data[1] = byte(param2 | command<<4 | (sfx>>5)<<7)
+	data[2] = byte(sfx&0x1F | octave<<5)
+
+	return nil
+}
+
+// SetCartMusic writes the tracks and patterns into the MUSIC and PATTERNS chunks of the cartridge, leaving the others
+// as they are
+func SetCartMusic(tic *cart.Cart, music *Music) {
+	if len(music.Tracks) > 0 {
+		data := make([]byte, MusicTracks*trackSize)
+		if chunk := tic.Chunk(cart.ChunkMusic, 0); chunk != nil {
+			copy(data, chunk.Data)
+		}
+		for index, track := range music.Tracks {
+			copy(data[index*trackSize:], track)
+		}
+		tic.SetChunk(cart.ChunkMusic, 0, cart.TrimTrailingZeros(data))
+	}
+
+	if len(music.Patterns) > 0 {
+		data := make([]byte, MusicPatterns*patternSize)
+		if chunk := tic.Chunk(cart.ChunkPatterns, 0); chunk != nil {
+			copy(data, chunk.Data)
+		}
+		for number, pattern := range music.Patterns {
+			copy(data[(number-1)*patternSize:], pattern)
+		}
+		tic.SetChunk(cart.ChunkPatterns, 0, cart.TrimTrailingZeros(data))
+	}
+}
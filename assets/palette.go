@@ -0,0 +1,230 @@
+package assets
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/novemberisms/ticc/cart"
+)
+
+// PaletteSize is the number of colors in a TIC-80 palette
+const PaletteSize = 16
+
+// A Palette holds the 16 colors that every pixel of the TIC-80 must be drawn with
+type Palette [PaletteSize]color.RGBA
+
+// DefaultPalette is the SWEETIE-16 palette that new TIC-80 cartridges start out with
+var DefaultPalette = Palette{
+	{0x1a, 0x1c, 0x2c, 0xff},
+	{0x5d, 0x27, 0x5d, 0xff},
+	{0xb1, 0x3e, 0x53, 0xff},
+	{0xef, 0x7d, 0x57, 0xff},
+	{0xff, 0xcd, 0x75, 0xff},
+	{0xa7, 0xf0, 0x70, 0xff},
+	{0x38, 0xb7, 0x64, 0xff},
+	{0x25, 0x71, 0x79, 0xff},
+	{0x29, 0x36, 0x6f, 0xff},
+	{0x3b, 0x5d, 0xc9, 0xff},
+	{0x41, 0xa6, 0xf6, 0xff},
+	{0x73, 0xef, 0xf7, 0xff},
+	{0xf4, 0xf4, 0xf4, 0xff},
+	{0x94, 0xb0, 0xc2, 0xff},
+	{0x56, 0x6c, 0x86, 0xff},
+	{0x33, 0x3c, 0x57, 0xff},
+}
+
+// CartPalette reads the palette out of the cartridge, falling back to the default palette if it has none
+func CartPalette(tic *cart.Cart) Palette {
+	chunk := tic.Chunk(cart.ChunkPalette, 0)
+
+	if chunk == nil {
+		return DefaultPalette
+	}
+
+	// the chunk may have been trimmed of trailing zeroes, which are simply black
+	data := make([]byte, PaletteSize*3)
+	copy(data, chunk.Data)
+
+	var palette Palette
+	for i := range palette {
+		palette[i] = color.RGBA{data[i*3], data[i*3+1], data[i*3+2], 0xff}
+	}
+
+	return palette
+}
+
+// SetCartPalette writes the palette into the PALETTE chunk of the cartridge. Anything after the 16 colors (like the
+// palette of the border) is left as it is.
+func SetCartPalette(tic *cart.Cart, palette Palette) {
+	data := make([]byte, PaletteSize*3)
+
+	if chunk := tic.Chunk(cart.ChunkPalette, 0); chunk != nil && len(chunk.Data) > len(data) {
+		data = append(data, chunk.Data[len(data):]...)
+	}
+
+	for i, c := range palette {
+		data[i*3], data[i*3+1], data[i*3+2] = c.R, c.G, c.B
+	}
+
+	tic.SetChunk(cart.ChunkPalette, 0, cart.TrimTrailingZeros(data))
+}
+
+// matches a color in a GIMP palette, which is 3 decimal numbers followed by an optional name
+var reGPLColor = regexp.MustCompile(`^\s*(\d+)\s+(\d+)\s+(\d+)\s*(.*?)\s*$`)
+
+// ImportPalette reads a palette file in one of these formats, depending on its extension:
+//
+//	.gpl    a GIMP palette, with an optional name after every color
+//	.hex    one RRGGBB color per line, as downloaded from Lospec
+//	.txt    a Paint.NET palette, with one AARRGGBB color per line and comments starting with ';'
+//
+// The file can have up to 16 colors. Any colors it leaves out are taken from the base palette. The names of the colors
+// are returned along with the palette, with an empty name for every color that has none.
+func ImportPalette(filename string, base Palette) (Palette, []string, error) {
+	palette := base
+	names := make([]string, PaletteSize)
+
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return palette, names, err
+	}
+	defer file.Close()
+
+	format := strings.ToLower(filepath.Ext(filename))
+	scanner := bufio.NewScanner(file)
+	count := 0
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" {
+			continue
+		}
+
+		var c color.RGBA
+		var name string
+
+		switch format {
+		case ".gpl":
+			matchInfo := reGPLColor.FindStringSubmatch(line)
+			if matchInfo == nil {
+				// the header, the name and columns of the palette, and comments
+				continue
+			}
+			channels := [3]uint8{}
+			for i := range channels {
+				value, err := strconv.ParseUint(matchInfo[i+1], 10, 8)
+				if err != nil {
+					return palette, names, fmt.Errorf("invalid color (line %d): %s", lineNumber, line)
+				}
+				channels[i] = uint8(value)
+			}
+			c = color.RGBA{channels[0], channels[1], channels[2], 0xff}
+			name = matchInfo[4]
+		case ".txt":
+			if strings.HasPrefix(line, ";") {
+				continue
+			}
+			// the alpha that comes first is ignored, since every color of the TIC-80 is opaque
+			if len(line) == 8 {
+				line = line[2:]
+			}
+			if c, err = parseHexColor(line); err != nil {
+				return palette, names, fmt.Errorf("invalid color (line %d): %w", lineNumber, err)
+			}
+		case ".hex":
+			if c, err = parseHexColor(strings.TrimPrefix(line, "#")); err != nil {
+				return palette, names, fmt.Errorf("invalid color (line %d): %w", lineNumber, err)
+			}
+		default:
+			return palette, names, fmt.Errorf("unknown palette format '%s'. Use .gpl, .hex or .txt instead", format)
+		}
+
+		if count == PaletteSize {
+			return palette, names, fmt.Errorf("the palette has more than %d colors", PaletteSize)
+		}
+
+		palette[count] = c
+		names[count] = name
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return palette, names, err
+	}
+
+	if count == 0 {
+		return palette, names, errors.New("the palette has no colors")
+	}
+
+	return palette, names, nil
+}
+
+func parseHexColor(digits string) (color.RGBA, error) {
+	channels, err := hex.DecodeString(digits)
+
+	if err != nil || len(channels) != 3 {
+		return color.RGBA{}, fmt.Errorf("'%s' is not a color in the RRGGBB format", digits)
+	}
+
+	return color.RGBA{channels[0], channels[1], channels[2], 0xff}, nil
+}
+
+// ExportGPL writes the palette as a GIMP palette, which can be imported again with ImportPalette. The colors are left
+// without names.
+func ExportGPL(filename string, palette Palette, name string) error {
+	var text strings.Builder
+
+	fmt.Fprintf(&text, "GIMP Palette\nName: %s\nColumns: %d\n#\n", name, PaletteSize/2)
+
+	for _, c := range palette {
+		fmt.Fprintf(&text, "%3d %3d %3d\n", c.R, c.G, c.B)
+	}
+
+	return ioutil.WriteFile(filename, []byte(text.String()), 0644)
+}
+
+// Colors returns the palette as a color.Palette, for use with paletted images
+func (p Palette) Colors() color.Palette {
+	colors := make(color.Palette, PaletteSize)
+	for i, c := range p {
+		colors[i] = c
+	}
+	return colors
+}
+
+// Index finds the index of the palette entry that exactly matches the given color. Fully transparent pixels
+// are taken to be color 0, which is the usual transparent color in the TIC-80.
+func (p Palette) Index(c color.Color) (int, error) {
+	rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+	if rgba.A == 0 {
+		return 0, nil
+	}
+
+	if rgba.A != 0xff {
+		return 0, fmt.Errorf("color %s is partially transparent", hexColor(rgba))
+	}
+
+	for i, entry := range p {
+		if entry.R == rgba.R && entry.G == rgba.G && entry.B == rgba.B {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("color %s is not in the palette", hexColor(rgba))
+}
+
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,423 @@
+package assets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/novemberisms/ticc/cart"
+)
+
+const (
+	// WaveformCount is the number of waveforms in a TIC-80 cartridge
+	WaveformCount = 16
+	// WaveformSamples is the number of 4 bit samples in every waveform
+	WaveformSamples = 32
+	// SFXCount is the number of sound effects in a TIC-80 cartridge
+	SFXCount = 64
+	// SFXTicks is the number of ticks in the envelopes of every sound effect
+	SFXTicks = 30
+
+	// every sound effect takes 2 bytes per tick, 2 bytes of settings and 1 byte for each of the 4 loops
+	sfxSize = SFXTicks*2 + 2 + 4
+	// the largest value of a 4 bit sample or volume
+	maxNibble = 15
+)
+
+// sfxRows are the names of the envelopes of a sound effect, in the order the TIC-80 stores their loops
+var sfxRows = []string{"wave", "volume", "arpeggio", "pitch"}
+
+// noteNames are the 12 notes of an octave as the TIC-80 numbers them
+var noteNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// matches a note like 'C-4' or 'F#2', the way trackers write them
+var reNote = regexp.MustCompile(`^([A-Ga-g])([-#]?)([0-7])$`)
+
+// ImportWaveformTable reads a text file of waveforms, with one waveform per line as its index followed by its 32
+// samples, one hex digit each. Spaces between the samples are ignored, and comments start with '#' as in ImportSFX:
+//
+//	# a sawtooth in waveform 3
+//	3: 0011 2233 4455 6677 8899 aabb ccdd eeff
+//
+// The samples of every waveform in the file are returned by their index.
+func ImportWaveformTable(filename string) (map[int][]byte, error) {
+	waveforms := make(map[int][]byte)
+
+	err := _scanLines(filename, func(lineNumber int, line string) error {
+		parts := strings.SplitN(line, ":", 2)
+
+		if len(parts) != 2 {
+			return fmt.Errorf("expected 'index: samples' (line %d): %s", lineNumber, line)
+		}
+
+		index, err := _parseRanged(strings.TrimSpace(parts[0]), 0, WaveformCount-1, "waveform index")
+		if err != nil {
+			return fmt.Errorf("%w (line %d)", err, lineNumber)
+		}
+
+		if _, duplicate := waveforms[index]; duplicate {
+			return fmt.Errorf("waveform %d is defined more than once (line %d)", index, lineNumber)
+		}
+
+		digits := strings.Join(strings.Fields(parts[1]), "")
+
+		if len(digits) != WaveformSamples {
+			return fmt.Errorf("waveform %d has %d samples, but it must have %d (line %d)", index, len(digits), WaveformSamples, lineNumber)
+		}
+
+		samples := make([]byte, WaveformSamples)
+		for i, digit := range digits {
+			value, err := strconv.ParseUint(string(digit), 16, 4)
+			if err != nil {
+				return fmt.Errorf("'%c' is not a sample from 0 to f (line %d)", digit, lineNumber)
+			}
+			samples[i] = byte(value)
+		}
+
+		waveforms[index] = samples
+		return nil
+	})
+
+	return waveforms, err
+}
+
+// ImportWaveformWAV reads a waveform from an uncompressed 8 or 16 bit WAV file, which must be exactly 32 samples long.
+// Only the first channel of a stereo file is used, and every sample is scaled down to 4 bits.
+func ImportWaveformWAV(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+
+	var channels, bitsPerSample int
+	var pcm []byte
+
+	// the file is a list of chunks, of which only the format and the samples matter
+	for offset := 12; offset+8 <= len(data); {
+		chunkID := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		start := offset + 8
+
+		if size < 0 || start+size > len(data) {
+			return nil, fmt.Errorf("the '%s' chunk is cut short", chunkID)
+		}
+
+		switch chunkID {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("the format chunk is cut short")
+			}
+			if format := binary.LittleEndian.Uint16(data[start:]); format != 1 {
+				return nil, fmt.Errorf("only uncompressed PCM samples are supported (the format is %d)", format)
+			}
+			channels = int(binary.LittleEndian.Uint16(data[start+2:]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(data[start+14:]))
+		case "data":
+			pcm = data[start : start+size]
+		}
+
+		// chunks are padded to an even size
+		offset = start + size + size%2
+	}
+
+	if channels == 0 || pcm == nil {
+		return nil, errors.New("the file has no format or no samples")
+	}
+
+	if bitsPerSample != 8 && bitsPerSample != 16 {
+		return nil, fmt.Errorf("only 8 and 16 bit samples are supported (the file has %d bit samples)", bitsPerSample)
+	}
+
+	frameSize := channels * bitsPerSample / 8
+
+	if frames := len(pcm) / frameSize; frames != WaveformSamples {
+		return nil, fmt.Errorf("the file has %d samples, but a waveform must have exactly %d", frames, WaveformSamples)
+	}
+
+	samples := make([]byte, WaveformSamples)
+	for i := range samples {
+		frame := pcm[i*frameSize:]
+		if bitsPerSample == 8 {
+			// 8 bit samples are unsigned
+			samples[i] = frame[0] >> 4
+		} else {
+			// 16 bit samples are signed
+			samples[i] = byte((int(int16(binary.LittleEndian.Uint16(frame))) + 0x8000) >> 12)
+		}
+	}
+
+	return samples, nil
+}
+
+// SetCartWaveforms writes the given waveforms into the WAVEFORM chunk of the cartridge, leaving the others as they are
+func SetCartWaveforms(tic *cart.Cart, waveforms map[int][]byte) {
+	data := make([]byte, WaveformCount*WaveformSamples/2)
+
+	if chunk := tic.Chunk(cart.ChunkWaveform, 0); chunk != nil {
+		copy(data, chunk.Data)
+	}
+
+	for index, samples := range waveforms {
+		packed := data[index*WaveformSamples/2:]
+		for i := 0; i < WaveformSamples; i += 2 {
+			// the first sample of every pair goes into the low nibble
+			packed[i/2] = samples[i] | samples[i+1]<<4
+		}
+	}
+
+	tic.SetChunk(cart.ChunkWaveform, 0, cart.TrimTrailingZeros(data))
+}
+
+// An SFX is a sound effect read from a text file, already in the format of the SAMPLES chunk
+type SFX struct {
+	Index int
+	// Name is the optional name given to the sound effect, or an empty string
+	Name string
+	Data []byte
+}
+
+// ImportSFX reads a text file of sound effects. Every sound effect starts with 'sfx', its index and an optional name,
+// followed by its envelopes and settings. Comments start with a '#' at the start of a line or after a space:
+//
+//	sfx 0 jump
+//	  wave     2                      # the waveform of every tick, from 0 to 15
+//	  volume   15 15 12 9 6 3 0       # the volume of every tick, from 0 (silent) to 15
+//	  arpeggio 0                      # the arpeggio of every tick, from 0 to 15
+//	  pitch    0 1 2 3 4              # the pitch of every tick, from -8 to 7
+//	  loop     volume 5 2             # the start and size of the loop of an envelope
+//	  note     C-4                    # the note played when none is given
+//	  speed    0                      # from -4 to 3
+//	  reverse                         # play the arpeggio backwards
+//	  pitch16x                        # multiply the pitch by 16
+//
+// An envelope can have up to 30 ticks, and any ticks it leaves out keep its last value. Envelopes that are left out
+// entirely are flat: waveform 0, volume 15, arpeggio 0 and pitch 0.
+func ImportSFX(filename string) ([]SFX, error) {
+	effects := []SFX{}
+	seen := make(map[int]bool)
+
+	var current *SFX
+	var rows [][]int
+
+	// the envelopes are only written out once every row of the sound effect is known
+	finish := func() {
+		if current == nil {
+			return
+		}
+		for tick := 0; tick < SFXTicks; tick++ {
+			wave, volume, arpeggio, pitch := rows[0][tick], rows[1][tick], rows[2][tick], rows[3][tick]
+			// the TIC-80 stores the volume inverted, so that silence is 15
+			current.Data[tick*2] = byte(maxNibble-volume) | byte(wave)<<4
+			current.Data[tick*2+1] = byte(arpeggio) | byte(pitch&0x0F)<<4
+		}
+		effects = append(effects, *current)
+	}
+
+	err := _scanLines(filename, func(lineNumber int, line string) error {
+		fields := strings.Fields(line)
+		keyword, values := strings.ToLower(fields[0]), fields[1:]
+
+		if keyword == "sfx" {
+			finish()
+
+			if len(values) < 1 || len(values) > 2 {
+				return fmt.Errorf("expected 'sfx index [name]' (line %d): %s", lineNumber, line)
+			}
+
+			index, err := _parseRanged(values[0], 0, SFXCount-1, "sfx index")
+			if err != nil {
+				return fmt.Errorf("%w (line %d)", err, lineNumber)
+			}
+			if seen[index] {
+				return fmt.Errorf("sfx %d is defined more than once (line %d)", index, lineNumber)
+			}
+			seen[index] = true
+
+			current = &SFX{Index: index, Data: make([]byte, sfxSize)}
+			if len(values) == 2 {
+				current.Name = values[1]
+			}
+
+			rows = [][]int{_extendRow([]int{0}), _extendRow([]int{maxNibble}), _extendRow([]int{0}), _extendRow([]int{0})}
+			return nil
+		}
+
+		if current == nil {
+			return fmt.Errorf("expected 'sfx index [name]' before anything else (line %d): %s", lineNumber, line)
+		}
+
+		settings := current.Data[SFXTicks*2:]
+
+		switch keyword {
+		case "wave", "volume", "arpeggio", "pitch":
+			if len(values) == 0 || len(values) > SFXTicks {
+				return fmt.Errorf("the %s envelope must have from 1 to %d ticks (line %d)", keyword, SFXTicks, lineNumber)
+			}
+			min, max := 0, maxNibble
+			if keyword == "pitch" {
+				min, max = -8, 7
+			}
+			row := make([]int, len(values))
+			for i, value := range values {
+				number, err := _parseRanged(value, min, max, keyword)
+				if err != nil {
+					return fmt.Errorf("%w (line %d)", err, lineNumber)
+				}
+				row[i] = number
+			}
+			rows[_indexOf(sfxRows, keyword)] = _extendRow(row)
+		case "loop":
+			if len(values) != 3 || _indexOf(sfxRows, values[0]) < 0 {
+				return fmt.Errorf("expected 'loop wave|volume|arpeggio|pitch start size' (line %d): %s", lineNumber, line)
+			}
+			start, err := _parseRanged(values[1], 0, maxNibble, "loop start")
+			if err != nil {
+				return fmt.Errorf("%w (line %d)", err, lineNumber)
+			}
+			size, err := _parseRanged(values[2], 0, maxNibble, "loop size")
+			if err != nil {
+				return fmt.Errorf("%w (line %d)", err, lineNumber)
+			}
+			settings[2+_indexOf(sfxRows, values[0])] = byte(start) | byte(size)<<4
+		case "note":
+			if len(values) != 1 {
+				return fmt.Errorf("expected 'note' and a note like C-4 (line %d): %s", lineNumber, line)
+			}
+			note, octave, err := ParseNote(values[0])
+			if err != nil {
+				return fmt.Errorf("%w (line %d)", err, lineNumber)
+			}
+			settings[0] = settings[0]&^0x07 | byte(octave)
+			settings[1] = settings[1]&^0x0F | byte(note)
+		case "speed":
+			if len(values) != 1 {
+				return fmt.Errorf("expected 'speed' and a number (line %d): %s", lineNumber, line)
+			}
+			speed, err := _parseRanged(values[0], -4, 3, "speed")
+			if err != nil {
+				return fmt.Errorf("%w (line %d)", err, lineNumber)
+			}
+			settings[0] = settings[0]&^0x70 | byte(speed&0x07)<<4
+		case "pitch16x":
+			settings[0] |= 0x08
+		case "reverse":
+			settings[0] |= 0x80
+		default:
+			return fmt.Errorf("unknown setting '%s' (line %d)", fields[0], lineNumber)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	finish()
+
+	return effects, nil
+}
+
+// SetCartSFX writes the given sound effects into the SAMPLES chunk of the cartridge, leaving the others as they are
+func SetCartSFX(tic *cart.Cart, effects []SFX) {
+	data := make([]byte, SFXCount*sfxSize)
+
+	if chunk := tic.Chunk(cart.ChunkSamples, 0); chunk != nil {
+		copy(data, chunk.Data)
+	}
+
+	for _, effect := range effects {
+		copy(data[effect.Index*sfxSize:], effect.Data)
+	}
+
+	tic.SetChunk(cart.ChunkSamples, 0, cart.TrimTrailingZeros(data))
+}
+
+// ParseNote reads a note written like 'C-4' or 'F#2' into the number of the note in its octave and the octave
+func ParseNote(text string) (note int, octave int, err error) {
+	matchInfo := reNote.FindStringSubmatch(text)
+
+	if matchInfo == nil {
+		return 0, 0, fmt.Errorf("'%s' is not a note like C-4 or F#2", text)
+	}
+
+	name := strings.ToUpper(matchInfo[1])
+	if matchInfo[2] == "#" {
+		name += "#"
+	}
+
+	note = _indexOf(noteNames, name)
+	if note < 0 {
+		return 0, 0, fmt.Errorf("'%s' is not a note like C-4 or F#2", text)
+	}
+
+	octave, _ = strconv.Atoi(matchInfo[3])
+
+	return note, octave, nil
+}
+
+// matches a comment, which starts with a '#' at the start of a line or after a space so that notes like F#2 are kept
+var reSoundComment = regexp.MustCompile(`(^|\s)#.*$`)
+
+// _scanLines calls the handler with every line of a text file that is not blank once comments are removed
+func _scanLines(filename string, handler func(lineNumber int, line string) error) error {
+	text, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(reSoundComment.ReplaceAllString(scanner.Text(), ""))
+
+		if line == "" {
+			continue
+		}
+
+		if err := handler(lineNumber, line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func _parseRanged(text string, min int, max int, what string) (int, error) {
+	value, err := strconv.Atoi(text)
+
+	if err != nil || value < min || value > max {
+		return 0, fmt.Errorf("the %s must be a number from %d to %d, not '%s'", what, min, max, text)
+	}
+
+	return value, nil
+}
+
+// _extendRow fills out an envelope to every tick by repeating its last value
+func _extendRow(row []int) []int {
+	for len(row) < SFXTicks {
+		row = append(row, row[len(row)-1])
+	}
+	return row
+}
+
+func _indexOf(list []string, value string) int {
+	for i, item := range list {
+		if item == value {
+			return i
+		}
+	}
+	return -1
+}
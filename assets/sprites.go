@@ -0,0 +1,306 @@
+package assets
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+
+	"github.com/novemberisms/ticc/cart"
+)
+
+const (
+	// TileSize is the width and height of a single tile in pixels
+	TileSize = 8
+	// TileBytes is the size of a single tile in memory, with 2 pixels packed into every byte
+	TileBytes = TileSize * TileSize / 2
+	// SheetTiles is the number of tiles across and down a sheet of 256 tiles
+	SheetTiles = 16
+	// SheetSize is the width and height of a sheet of 256 tiles in pixels
+	SheetSize = SheetTiles * TileSize
+)
+
+// ImportSpriteSheet reads a PNG image of up to 128x128 pixels and converts it into the 256 tiles of a TILES or SPRITES
+// chunk. The image is laid over the top-left of the sheet, so its width and height must be multiples of 8. Every pixel
+// must exactly match one of the colors of the palette.
+func ImportSpriteSheet(filename string, palette Palette) ([]byte, error) {
+	img, err := readPNG(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+
+	if bounds.Dx()%TileSize != 0 || bounds.Dy()%TileSize != 0 || bounds.Dx() > SheetSize || bounds.Dy() > SheetSize {
+		return nil, fmt.Errorf("sprite sheet is %dx%d, but it must be at most %dx%d with sides that are multiples of %d", bounds.Dx(), bounds.Dy(), SheetSize, SheetSize, TileSize)
+	}
+
+	pixels, err := quantize(img, palette)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := make([]byte, SheetTiles*SheetTiles*TileBytes)
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			tile := (y/TileSize)*SheetTiles + x/TileSize
+			setTilePixel(sheet[tile*TileBytes:], x%TileSize, y%TileSize, pixels[y*bounds.Dx()+x])
+		}
+	}
+
+	return sheet, nil
+}
+
+// ExportSpriteSheet writes the 256 tiles of a TILES or SPRITES chunk as a 128x128 PNG image. The image is paletted
+// with the colors of the palette in order, so importing it again gives back exactly the same tiles.
+func ExportSpriteSheet(filename string, sheet []byte, palette Palette) error {
+	// the chunk may have been trimmed of trailing zeroes
+	data := make([]byte, SheetTiles*SheetTiles*TileBytes)
+	copy(data, sheet)
+
+	img := image.NewPaletted(image.Rect(0, 0, SheetSize, SheetSize), palette.Colors())
+
+	for y := 0; y < SheetSize; y++ {
+		for x := 0; x < SheetSize; x++ {
+			tile := (y/TileSize)*SheetTiles + x/TileSize
+			img.SetColorIndex(x, y, tilePixel(data[tile*TileBytes:], x%TileSize, y%TileSize))
+		}
+	}
+
+	return writePNG(filename, img)
+}
+
+// A PackedSprite is an image from a directory of sprites that PackSprites has placed in the sprite bank
+type PackedSprite struct {
+	Filename string
+	// Index is the number of the sprite at its top-left, from 256 to 511, to be passed to spr()
+	Index int
+	// Width and Height are the size of the sprite in tiles, to be passed to spr() along with Index
+	Width  int
+	Height int
+}
+
+// PackSprites draws every image into the SPRITES chunk of the cartridge, in order. Images larger than 8x8 take up a
+// block of tiles that spr() can draw all at once, and each one goes in the first place it fits, going row by row.
+// Sprites that are marked as reserved (by their number, from 0 to 511) are never drawn over. Every pixel must exactly
+// match one of the colors of the palette.
+func PackSprites(tic *cart.Cart, filenames []string, reserved []bool, palette Palette) ([]PackedSprite, error) {
+	const bankStart = SheetTiles * SheetTiles
+
+	sheet := make([]byte, SheetTiles*SheetTiles*TileBytes)
+	if chunk := tic.Chunk(cart.ChunkSprites, 0); chunk != nil {
+		copy(sheet, chunk.Data)
+	}
+
+	used := make([]bool, SheetTiles*SheetTiles)
+	for i := range used {
+		used[i] = bankStart+i < len(reserved) && reserved[bankStart+i]
+	}
+
+	packed := []PackedSprite{}
+
+	for _, filename := range filenames {
+		img, err := readPNG(filename)
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %w", filename, err)
+		}
+
+		bounds := img.Bounds()
+		if bounds.Dx()%TileSize != 0 || bounds.Dy()%TileSize != 0 || bounds.Dx() > SheetSize || bounds.Dy() > SheetSize {
+			return nil, fmt.Errorf("'%s' is %dx%d, but a sprite must be at most %dx%d with sides that are multiples of %d", filename, bounds.Dx(), bounds.Dy(), SheetSize, SheetSize, TileSize)
+		}
+
+		pixels, err := quantize(img, palette)
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %w", filename, err)
+		}
+
+		width, height := bounds.Dx()/TileSize, bounds.Dy()/TileSize
+		position := _findFreeBlock(used, width, height)
+
+		if position < 0 {
+			return nil, fmt.Errorf("there is no room left in the sprite bank for '%s' (%dx%d tiles)", filename, width, height)
+		}
+
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				tile := position + (y/TileSize)*SheetTiles + x/TileSize
+				used[tile] = true
+				setTilePixel(sheet[tile*TileBytes:], x%TileSize, y%TileSize, pixels[y*bounds.Dx()+x])
+			}
+		}
+
+		packed = append(packed, PackedSprite{filename, bankStart + position, width, height})
+	}
+
+	tic.SetChunk(cart.ChunkSprites, 0, cart.TrimTrailingZeros(sheet))
+
+	return packed, nil
+}
+
+// _findFreeBlock finds the first tile, going row by row, where a block of the given size in tiles fits without
+// covering any used tiles or running off the right edge of the sheet. It returns -1 if there is no such tile.
+func _findFreeBlock(used []bool, width int, height int) int {
+	for row := 0; row+height <= SheetTiles; row++ {
+		for column := 0; column+width <= SheetTiles; column++ {
+			if _blockIsFree(used, row, column, width, height) {
+				return row*SheetTiles + column
+			}
+		}
+	}
+	return -1
+}
+
+func _blockIsFree(used []bool, row int, column int, width int, height int) bool {
+	for y := row; y < row+height; y++ {
+		for x := column; x < column+width; x++ {
+			if used[y*SheetTiles+x] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// TileIsEmpty determines if every pixel of the given tile of a sheet is color 0
+func TileIsEmpty(sheet []byte, tile int) bool {
+	for _, b := range sheet[tile*TileBytes : (tile+1)*TileBytes] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// tilePixel reads a single palette index out of a tile, the reverse of setTilePixel
+func tilePixel(tile []byte, x int, y int) byte {
+	packed := tile[(y*TileSize+x)/2]
+	if x%2 == 0 {
+		return packed & 0x0F
+	}
+	return packed >> 4
+}
+
+// setTilePixel writes a single palette index into a tile in the 4 bits per pixel layout of the TIC-80,
+// where the left pixel of every pair is stored in the low nibble of the byte
+func setTilePixel(tile []byte, x int, y int, index byte) {
+	offset := (y*TileSize + x) / 2
+	if x%2 == 0 {
+		tile[offset] = tile[offset]&0xF0 | index&0x0F
+	} else {
+		tile[offset] = tile[offset]&0x0F | index<<4
+	}
+}
+
+func readPNG(filename string) (image.Image, error) {
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return png.Decode(file)
+}
+
+func writePNG(filename string, img image.Image) error {
+	file, err := os.Create(filename)
+
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// quantize converts every pixel of the image into an index of the palette, going row by row. If the image already
+// uses the palette in the same order, its indices are used as they are, which keeps palettes with repeated colors
+// intact.
+func quantize(img image.Image, palette Palette) ([]byte, error) {
+	if pixels, ok := _palettedIndices(img, palette); ok {
+		return pixels, nil
+	}
+
+	bounds := img.Bounds()
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy())
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			index, err := palette.Index(img.At(x, y))
+			if err != nil {
+				return nil, fmt.Errorf("pixel (%d, %d): %w", x-bounds.Min.X, y-bounds.Min.Y, err)
+			}
+			pixels = append(pixels, byte(index))
+		}
+	}
+
+	return pixels, nil
+}
+
+// quantizeNearest is like quantize, except that any color is allowed and becomes the closest color of the palette
+func quantizeNearest(img image.Image, palette Palette) []byte {
+	if pixels, ok := _palettedIndices(img, palette); ok {
+		return pixels
+	}
+
+	bounds := img.Bounds()
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	colors := palette.Colors()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.At(x, y)
+			if _, _, _, a := c.RGBA(); a == 0 {
+				pixels = append(pixels, 0)
+				continue
+			}
+			pixels = append(pixels, byte(colors.Index(c)))
+		}
+	}
+
+	return pixels
+}
+
+func _palettedIndices(img image.Image, palette Palette) ([]byte, bool) {
+	paletted, ok := img.(*image.Paletted)
+
+	if !ok || !_sameColors(paletted.Palette, palette) {
+		return nil, false
+	}
+
+	bounds := img.Bounds()
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy())
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixels = append(pixels, paletted.ColorIndexAt(x, y))
+		}
+	}
+
+	return pixels, true
+}
+
+func _sameColors(colors color.Palette, palette Palette) bool {
+	if len(colors) > PaletteSize {
+		return false
+	}
+
+	for i, c := range colors {
+		r, g, b, a := c.RGBA()
+		pr, pg, pb, _ := palette[i].RGBA()
+		if r != pr || g != pg || b != pb || a != 0xffff {
+			return false
+		}
+	}
+
+	return true
+}
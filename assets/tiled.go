@@ -0,0 +1,478 @@
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/novemberisms/ticc/literal"
+)
+
+const (
+	// MapWidth is the width of the TIC-80 map in tiles
+	MapWidth = 240
+	// MapHeight is the height of the TIC-80 map in tiles
+	MapHeight = 136
+	// the top bits of a tiled GID say whether the tile is flipped or rotated, which the TIC-80 map cannot store
+	gidFlagsMask = 0x0FFFFFFF
+)
+
+// A TiledMap is a map made in the Tiled editor, converted into the data of a MAP chunk and the objects of its object
+// layers
+type TiledMap struct {
+	// Tiles holds one tile index per cell of the map, going row by row
+	Tiles []byte
+	// Entities holds a *literal.Map with the name, x, y and properties of every object in the object layers
+	Entities []interface{}
+}
+
+// the parts of a .tmj file that are relevant to the TIC-80
+type tiledJSONMap struct {
+	Infinite bool              `json:"infinite"`
+	Layers   []tiledJSONLayer  `json:"layers"`
+	Tilesets []tiledTilesetRef `json:"tilesets"`
+}
+
+type tiledJSONLayer struct {
+	Type        string            `json:"type"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	Data        json.RawMessage   `json:"data"`
+	Encoding    string            `json:"encoding"`
+	Compression string            `json:"compression"`
+	Objects     []tiledJSONObject `json:"objects"`
+	Layers      []tiledJSONLayer  `json:"layers"`
+}
+
+type tiledJSONObject struct {
+	Name       string      `json:"name"`
+	X          json.Number `json:"x"`
+	Y          json.Number `json:"y"`
+	Properties []struct {
+		Name  string      `json:"name"`
+		Value interface{} `json:"value"`
+	} `json:"properties"`
+}
+
+type tiledTilesetRef struct {
+	FirstGID uint32 `json:"firstgid" xml:"firstgid,attr"`
+}
+
+// the parts of a .tmx file that are relevant to the TIC-80
+type tiledXMLMap struct {
+	Infinite bool              `xml:"infinite,attr"`
+	Tilesets []tiledTilesetRef `xml:"tileset"`
+	tiledXMLGroup
+}
+
+// the layers of the map itself and of a layer group are read the same way
+type tiledXMLGroup struct {
+	Layers []struct {
+		Width  int `xml:"width,attr"`
+		Height int `xml:"height,attr"`
+		Data   struct {
+			Encoding    string `xml:"encoding,attr"`
+			Compression string `xml:"compression,attr"`
+			Tiles       []struct {
+				GID uint32 `xml:"gid,attr"`
+			} `xml:"tile"`
+			Text string `xml:",chardata"`
+		} `xml:"data"`
+	} `xml:"layer"`
+	ObjectGroups []struct {
+		Objects []struct {
+			Name       string `xml:"name,attr"`
+			X          string `xml:"x,attr"`
+			Y          string `xml:"y,attr"`
+			Properties []struct {
+				Name  string `xml:"name,attr"`
+				Type  string `xml:"type,attr"`
+				Value string `xml:"value,attr"`
+				Text  string `xml:",chardata"`
+			} `xml:"properties>property"`
+		} `xml:"object"`
+	} `xml:"objectgroup"`
+	Groups []tiledXMLGroup `xml:"group"`
+}
+
+// ImportTiledMap reads a map saved by Tiled as either JSON (.tmj or .json) or XML (.tmx). Its first tile layer must be
+// exactly 240x136 tiles, and may only use the first 256 tiles of its tilesets. Any object layers become entities.
+func ImportTiledMap(filename string) (*TiledMap, error) {
+	data, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".tmx":
+		return parseTMX(data)
+	default:
+		return parseTMJ(data)
+	}
+}
+
+func parseTMJ(data []byte) (*TiledMap, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// keep the numbers of the properties as they are written instead of turning them all into floats
+	decoder.UseNumber()
+
+	var tmj tiledJSONMap
+
+	if err := decoder.Decode(&tmj); err != nil {
+		return nil, err
+	}
+
+	if tmj.Infinite {
+		return nil, errors.New("infinite maps are not supported, since the TIC-80 map has a fixed size")
+	}
+
+	result := &TiledMap{Entities: []interface{}{}}
+	var gids []uint32
+
+	var readLayers func(layers []tiledJSONLayer) error
+	readLayers = func(layers []tiledJSONLayer) error {
+		for _, layer := range layers {
+			switch layer.Type {
+			case "tilelayer":
+				if gids != nil {
+					continue
+				}
+				if err := checkLayerSize(layer.Width, layer.Height); err != nil {
+					return err
+				}
+				var err error
+				if gids, err = decodeJSONLayerData(layer); err != nil {
+					return err
+				}
+			case "objectgroup":
+				for _, object := range layer.Objects {
+					properties := &literal.Map{}
+					for _, property := range object.Properties {
+						properties.Set(property.Name, property.Value)
+					}
+					result.Entities = append(result.Entities, newEntity(object.Name, object.X, object.Y, properties))
+				}
+			case "group":
+				if err := readLayers(layer.Layers); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := readLayers(tmj.Layers); err != nil {
+		return nil, err
+	}
+
+	if err := result.setTiles(gids, tmj.Tilesets); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func decodeJSONLayerData(layer tiledJSONLayer) ([]uint32, error) {
+	if layer.Encoding == "base64" {
+		var text string
+		if err := json.Unmarshal(layer.Data, &text); err != nil {
+			return nil, err
+		}
+		return decodeBase64Tiles(text, layer.Compression)
+	}
+
+	var gids []uint32
+	if err := json.Unmarshal(layer.Data, &gids); err != nil {
+		return nil, err
+	}
+	return gids, nil
+}
+
+func parseTMX(data []byte) (*TiledMap, error) {
+	var tmx tiledXMLMap
+
+	if err := xml.Unmarshal(data, &tmx); err != nil {
+		return nil, err
+	}
+
+	if tmx.Infinite {
+		return nil, errors.New("infinite maps are not supported, since the TIC-80 map has a fixed size")
+	}
+
+	result := &TiledMap{Entities: []interface{}{}}
+	var gids []uint32
+
+	var readGroup func(group tiledXMLGroup) error
+	readGroup = func(group tiledXMLGroup) error {
+		for _, layer := range group.Layers {
+			if gids != nil {
+				break
+			}
+			if err := checkLayerSize(layer.Width, layer.Height); err != nil {
+				return err
+			}
+
+			var err error
+			switch layer.Data.Encoding {
+			case "csv":
+				gids, err = decodeCSVTiles(layer.Data.Text)
+			case "base64":
+				gids, err = decodeBase64Tiles(layer.Data.Text, layer.Data.Compression)
+			default:
+				gids = make([]uint32, len(layer.Data.Tiles))
+				for i, tile := range layer.Data.Tiles {
+					gids[i] = tile.GID
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, objectGroup := range group.ObjectGroups {
+			for _, object := range objectGroup.Objects {
+				properties := &literal.Map{}
+				for _, property := range object.Properties {
+					value := property.Value
+					// multiline strings are written as the text of the property instead of its value
+					if value == "" {
+						value = property.Text
+					}
+					properties.Set(property.Name, typedPropertyValue(property.Type, value))
+				}
+				result.Entities = append(result.Entities, newEntity(object.Name, json.Number(object.X), json.Number(object.Y), properties))
+			}
+		}
+
+		for _, subgroup := range group.Groups {
+			if err := readGroup(subgroup); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := readGroup(tmx.tiledXMLGroup); err != nil {
+		return nil, err
+	}
+
+	if err := result.setTiles(gids, tmx.Tilesets); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// typedPropertyValue converts the value of a property in a .tmx file, which is always written as text, into the
+// type given to the property in tiled
+func typedPropertyValue(propertyType string, value string) interface{} {
+	switch propertyType {
+	case "int", "object":
+		if number, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return number
+		}
+	case "float":
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	case "bool":
+		return value == "true"
+	}
+	return value
+}
+
+func newEntity(name string, x json.Number, y json.Number, properties *literal.Map) *literal.Map {
+	// tiled leaves the coordinates out of a .tmx file when they are zero
+	if x == "" {
+		x = "0"
+	}
+	if y == "" {
+		y = "0"
+	}
+
+	entity := &literal.Map{}
+	entity.Set("name", name)
+	entity.Set("x", x)
+	entity.Set("y", y)
+	entity.Set("properties", properties)
+	return entity
+}
+
+func checkLayerSize(width int, height int) error {
+	if width != MapWidth || height != MapHeight {
+		return fmt.Errorf("the tile layer is %dx%d, but it must be exactly %dx%d to fit the TIC-80 map", width, height, MapWidth, MapHeight)
+	}
+	return nil
+}
+
+func decodeCSVTiles(text string) ([]uint32, error) {
+	var gids []uint32
+
+	for _, field := range strings.Split(text, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		gid, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tile in layer data: %w", err)
+		}
+		gids = append(gids, uint32(gid))
+	}
+
+	return gids, nil
+}
+
+// decodeBase64Tiles decodes layer data made of little-endian 32 bit GIDs, encoded in base64 and possibly compressed
+func decodeBase64Tiles(text string, compression string) ([]uint32, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch compression {
+	case "":
+	case "zlib":
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = ioutil.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	case "gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = ioutil.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported layer compression '%s'. Use csv, uncompressed base64, zlib or gzip instead", compression)
+	}
+
+	gids := make([]uint32, len(data)/4)
+	for i := range gids {
+		gids[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+
+	return gids, nil
+}
+
+// setTiles converts the GIDs of the tile layer into TIC-80 tile indices. A GID counts up from the first GID of the
+// tileset it belongs to, with 0 being an empty cell, which becomes tile 0 on the TIC-80.
+func (m *TiledMap) setTiles(gids []uint32, tilesets []tiledTilesetRef) error {
+	if gids == nil {
+		return errors.New("the map has no tile layer")
+	}
+
+	if len(gids) != MapWidth*MapHeight {
+		return fmt.Errorf("the tile layer has %d tiles, but it must have exactly %d", len(gids), MapWidth*MapHeight)
+	}
+
+	m.Tiles = make([]byte, len(gids))
+
+	for i, gid := range gids {
+		gid &= gidFlagsMask
+		if gid == 0 {
+			continue
+		}
+
+		// the tileset a GID belongs to is the one with the highest first GID that is not above it
+		var firstGID uint32
+		for _, tileset := range tilesets {
+			if tileset.FirstGID <= gid && tileset.FirstGID > firstGID {
+				firstGID = tileset.FirstGID
+			}
+		}
+
+		if firstGID == 0 {
+			return fmt.Errorf("the tile at %d,%d does not belong to any tileset", i%MapWidth, i/MapWidth)
+		}
+
+		tile := gid - firstGID
+		if tile > 0xFF {
+			return fmt.Errorf("the tile at %d,%d is tile %d of its tileset, but the TIC-80 map can only use the first 256 tiles", i%MapWidth, i/MapWidth, tile)
+		}
+		m.Tiles[i] = byte(tile)
+	}
+
+	return nil
+}
+
+// ExportTiledMap writes the tiles of a MAP chunk as a map that can be edited in Tiled and imported again with
+// ImportTiledMap. The map uses the sprite sheet image at tilesetImage (relative to the map) as its only tileset.
+func ExportTiledMap(filename string, tiles []byte, tilesetImage string) error {
+	// the chunk may have been trimmed of trailing zeroes
+	data := make([]byte, MapWidth*MapHeight)
+	copy(data, tiles)
+
+	// every cell holds a tile on the TIC-80, so none of them are left empty with GID 0
+	gids := make([]uint32, len(data))
+	for i, tile := range data {
+		gids[i] = uint32(tile) + 1
+	}
+
+	tmj := map[string]interface{}{
+		"type":         "map",
+		"orientation":  "orthogonal",
+		"renderorder":  "right-down",
+		"width":        MapWidth,
+		"height":       MapHeight,
+		"tilewidth":    TileSize,
+		"tileheight":   TileSize,
+		"infinite":     false,
+		"nextlayerid":  2,
+		"nextobjectid": 1,
+		"layers": []interface{}{
+			map[string]interface{}{
+				"id":      1,
+				"name":    "map",
+				"type":    "tilelayer",
+				"x":       0,
+				"y":       0,
+				"width":   MapWidth,
+				"height":  MapHeight,
+				"opacity": 1,
+				"visible": true,
+				"data":    gids,
+			},
+		},
+		"tilesets": []interface{}{
+			map[string]interface{}{
+				"firstgid":    1,
+				"name":        "tiles",
+				"image":       tilesetImage,
+				"imagewidth":  SheetSize,
+				"imageheight": SheetSize,
+				"tilewidth":   TileSize,
+				"tileheight":  TileSize,
+				"tilecount":   SheetTiles * SheetTiles,
+				"columns":     SheetTiles,
+				"margin":      0,
+				"spacing":     0,
+			},
+		},
+	}
+
+	encoded, err := json.Marshal(tmj)
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, encoded, 0644)
+}
@@ -0,0 +1,98 @@
+package blob
+
+import "strings"
+
+// FirstChar is the character that stands for 6 zero bits. The others follow it in ASCII order.
+const FirstChar = '0'
+
+const (
+	// the most bytes a block can copy as they are
+	maxLiteral = 128
+	// the fewest and most times a block can repeat a byte. Shorter runs are cheaper to copy as they are
+	minRun = 3
+	maxRun = 130
+	// control bytes at or above this one start a run
+	runControl = 128
+)
+
+// Pack compresses the data and encodes it as a string, which the decoder every language service provides turns back
+// into a list of bytes at runtime.
+//
+// The bytes are first compressed with run-length encoding, as a series of blocks that each start with a control
+// byte c:
+//
+//	c < 128     the next c+1 bytes are copied as they are
+//	c >= 128    the next byte is repeated c-125 times (3 to 130 times)
+//
+// The compressed bytes are then written 6 bits at a time, most significant bits first, as the 64 characters from
+// '0' to 'o'. Those are all printable ASCII, so the string survives any editor and the TIC-80 itself, and a decoder
+// only has to subtract FirstChar from every character to get its bits back.
+func Pack(data []byte) string {
+	return Encode(Compress(data))
+}
+
+// Compress compresses the data with the run-length encoding described in Pack
+func Compress(data []byte) []byte {
+	compressed := []byte{}
+	literalStart := 0
+
+	// writes the bytes since the end of the last block as blocks that copy them as they are
+	flushLiterals := func(end int) {
+		for literalStart < end {
+			count := end - literalStart
+			if count > maxLiteral {
+				count = maxLiteral
+			}
+			compressed = append(compressed, byte(count-1))
+			compressed = append(compressed, data[literalStart:literalStart+count]...)
+			literalStart += count
+		}
+	}
+
+	for i := 0; i < len(data); {
+		run := 1
+		for i+run < len(data) && data[i+run] == data[i] && run < maxRun {
+			run++
+		}
+
+		if run < minRun {
+			i++
+			continue
+		}
+
+		flushLiterals(i)
+		compressed = append(compressed, byte(runControl+run-minRun), data[i])
+		i += run
+		literalStart = i
+	}
+
+	flushLiterals(len(data))
+
+	return compressed
+}
+
+// Encode writes the bytes 6 bits at a time as the characters from FirstChar on. The last character is padded with
+// zero bits, which the decoders ignore since they do not make up a whole byte.
+func Encode(data []byte) string {
+	var encoded strings.Builder
+
+	bits, count := 0, 0
+
+	for _, b := range data {
+		bits = bits<<8 | int(b)
+		count += 8
+
+		for count >= 6 {
+			count -= 6
+			encoded.WriteByte(byte(FirstChar + bits>>count&0x3F))
+		}
+
+		bits &= 1<<count - 1
+	}
+
+	if count > 0 {
+		encoded.WriteByte(byte(FirstChar + bits<<(6-count)&0x3F))
+	}
+
+	return encoded.String()
+}
@@ -0,0 +1,403 @@
+package cart
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// ChunkType is an enum for the kinds of data a chunk of a TIC-80 cartridge can hold
+type ChunkType byte
+
+const (
+	// ChunkTiles holds the 256 background tiles of a bank
+	ChunkTiles ChunkType = 1
+	// ChunkSprites holds the 256 foreground sprites of a bank
+	ChunkSprites ChunkType = 2
+	// ChunkCoverDep is the old GIF cover image, which has since been replaced by ChunkScreen
+	ChunkCoverDep ChunkType = 3
+	// ChunkMap holds the 240x136 tile map of a bank
+	ChunkMap ChunkType = 4
+	// ChunkCode holds up to 64K of source code. Larger programs are spread across the banks
+	ChunkCode ChunkType = 5
+	// ChunkFlags holds the sprite flags of a bank
+	ChunkFlags ChunkType = 6
+	// ChunkSamples holds the 64 sound effects of a bank
+	ChunkSamples ChunkType = 9
+	// ChunkWaveform holds the 16 waveforms used by the sound effects
+	ChunkWaveform ChunkType = 10
+	// ChunkPalette holds the 16 color palettes of a bank
+	ChunkPalette ChunkType = 12
+	// ChunkPatternsDep is the old music pattern format, which has since been replaced by ChunkPatterns
+	ChunkPatternsDep ChunkType = 13
+	// ChunkMusic holds the 8 music tracks of a bank
+	ChunkMusic ChunkType = 14
+	// ChunkPatterns holds the 60 music patterns of a bank
+	ChunkPatterns ChunkType = 15
+	// ChunkCodeZip holds zlib compressed source code, and is only found in old cartridges
+	ChunkCodeZip ChunkType = 16
+	// ChunkDefault marks a cartridge that uses the default assets
+	ChunkDefault ChunkType = 17
+	// ChunkScreen holds the 240x136 cover image of the cartridge
+	ChunkScreen ChunkType = 18
+	// ChunkBinary holds a compiled binary for the wasm runtime
+	ChunkBinary ChunkType = 19
+	// ChunkLang holds the name of the scripting language of the cartridge
+	ChunkLang ChunkType = 20
+)
+
+func (t ChunkType) String() string {
+	switch t {
+	case ChunkTiles:
+		return "TILES"
+	case ChunkSprites:
+		return "SPRITES"
+	case ChunkCoverDep:
+		return "COVER_DEP"
+	case ChunkMap:
+		return "MAP"
+	case ChunkCode:
+		return "CODE"
+	case ChunkFlags:
+		return "FLAGS"
+	case ChunkSamples:
+		return "SAMPLES"
+	case ChunkWaveform:
+		return "WAVEFORM"
+	case ChunkPalette:
+		return "PALETTE"
+	case ChunkPatternsDep:
+		return "PATTERNS_DEP"
+	case ChunkMusic:
+		return "MUSIC"
+	case ChunkPatterns:
+		return "PATTERNS"
+	case ChunkCodeZip:
+		return "CODE_ZIP"
+	case ChunkDefault:
+		return "DEFAULT"
+	case ChunkScreen:
+		return "SCREEN"
+	case ChunkBinary:
+		return "BINARY"
+	case ChunkLang:
+		return "LANG"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", byte(t))
+	}
+}
+
+// ChunkTypeByName finds the chunk type with the given name (like "TILES" or "samples"), ignoring case
+func ChunkTypeByName(name string) (ChunkType, bool) {
+	for t := ChunkType(1); t <= maxChunkType; t++ {
+		if strings.EqualFold(t.String(), name) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+const (
+	// CodeBankSize is the most code that can fit in a single code chunk
+	CodeBankSize = 0x10000
+	// CodeBanks is the number of banks that code can be spread across
+	CodeBanks = 8
+
+	// every chunk starts with a 4 byte header: 5 bits of type, 3 bits of bank, 16 bits of size, and 8 unused bits
+	chunkHeaderSize = 4
+	maxChunkSize    = 0xFFFF
+	// the type takes up 5 bits of the header
+	maxChunkType ChunkType = 0x1F
+)
+
+// bankChunkOrder is the order that the TIC-80 saves the chunks of each bank in. New chunks are added to a cartridge
+// in this order, so that a cartridge built up from nothing comes out the same as one saved by the TIC-80.
+var bankChunkOrder = []ChunkType{
+	ChunkTiles,
+	ChunkSprites,
+	ChunkMap,
+	ChunkSamples,
+	ChunkWaveform,
+	ChunkMusic,
+	ChunkPatterns,
+	ChunkFlags,
+	ChunkScreen,
+	ChunkPalette,
+}
+
+// A Chunk is a single block of data in a cartridge, like the tiles or the code of one of the banks
+type Chunk struct {
+	Type ChunkType
+	Bank int
+	Data []byte
+
+	// the unused last byte of the header, kept around so that carts are written back exactly as they were read
+	reserved byte
+}
+
+// A Cart is a TIC-80 cartridge, which is simply a list of chunks
+type Cart struct {
+	Chunks []*Chunk
+}
+
+// New creates an empty cartridge with no chunks
+func New() *Cart {
+	return &Cart{
+		Chunks: make([]*Chunk, 0),
+	}
+}
+
+// Load reads and parses the cartridge at the given path, which may be either a .tic file or a PNG image with a
+// cartridge embedded in it
+func Load(path string) (*Cart, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if isPNG(data) {
+		return ParsePNG(data)
+	}
+
+	return Parse(data)
+}
+
+// Parse reads a cartridge from the raw bytes of a .tic file
+func Parse(data []byte) (*Cart, error) {
+	cart := New()
+
+	for offset := 0; offset < len(data); {
+		if offset+chunkHeaderSize > len(data) {
+			return nil, fmt.Errorf("cartridge is truncated: incomplete chunk header at byte %d", offset)
+		}
+
+		header := data[offset : offset+chunkHeaderSize]
+		chunkType := ChunkType(header[0] & 0x1F)
+		bank := int(header[0] >> 5)
+		size := int(binary.LittleEndian.Uint16(header[1:3]))
+
+		// a full bank of code does not fit in 16 bits, so the TIC-80 saves its size as 0 instead
+		if size == 0 && (chunkType == ChunkCode || chunkType == ChunkBinary) {
+			size = CodeBankSize
+		}
+
+		offset += chunkHeaderSize
+
+		if offset+size > len(data) {
+			return nil, fmt.Errorf("cartridge is truncated: %s chunk in bank %d needs %d bytes but only %d are left", chunkType, bank, size, len(data)-offset)
+		}
+
+		chunkData := make([]byte, size)
+		copy(chunkData, data[offset:offset+size])
+		offset += size
+
+		cart.Chunks = append(cart.Chunks, &Chunk{
+			Type:     chunkType,
+			Bank:     bank,
+			Data:     chunkData,
+			reserved: header[3],
+		})
+	}
+
+	return cart, nil
+}
+
+// Bytes serializes the cartridge into the binary .tic format. Chunks without any data are left out, except for the
+// DEFAULT chunk which never has any.
+func (c *Cart) Bytes() ([]byte, error) {
+	var buffer bytes.Buffer
+
+	for _, chunk := range c.Chunks {
+		size := len(chunk.Data)
+
+		if size == 0 && chunk.Type != ChunkDefault {
+			continue
+		}
+
+		if chunk.Bank < 0 || chunk.Bank >= CodeBanks {
+			return nil, fmt.Errorf("%s chunk has an invalid bank %d", chunk.Type, chunk.Bank)
+		}
+
+		if size == CodeBankSize && (chunk.Type == ChunkCode || chunk.Type == ChunkBinary) {
+			size = 0
+		} else if size > maxChunkSize {
+			return nil, fmt.Errorf("%s chunk in bank %d is too large (%d bytes)", chunk.Type, chunk.Bank, size)
+		}
+
+		header := [chunkHeaderSize]byte{byte(chunk.Type)&0x1F | byte(chunk.Bank)<<5, 0, 0, chunk.reserved}
+		binary.LittleEndian.PutUint16(header[1:3], uint16(size))
+
+		buffer.Write(header[:])
+		buffer.Write(chunk.Data)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Save serializes the cartridge and writes it to the given path
+func (c *Cart) Save(path string) error {
+	data, err := c.Bytes()
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Chunk finds the chunk with the given type in the given bank, or returns nil if the cartridge has none
+func (c *Cart) Chunk(chunkType ChunkType, bank int) *Chunk {
+	for _, chunk := range c.Chunks {
+		if chunk.Type == chunkType && chunk.Bank == bank {
+			return chunk
+		}
+	}
+	return nil
+}
+
+// SetChunk replaces the data of the chunk with the given type and bank. If there is none yet, a new chunk is added
+// in the same place the TIC-80 would put it.
+func (c *Cart) SetChunk(chunkType ChunkType, bank int, data []byte) {
+	if chunk := c.Chunk(chunkType, bank); chunk != nil {
+		chunk.Data = data
+		return
+	}
+
+	newChunk := &Chunk{
+		Type: chunkType,
+		Bank: bank,
+		Data: data,
+	}
+
+	insertAt := len(c.Chunks)
+	for i, chunk := range c.Chunks {
+		if chunk.order() > newChunk.order() {
+			insertAt = i
+			break
+		}
+	}
+
+	c.Chunks = append(c.Chunks, nil)
+	copy(c.Chunks[insertAt+1:], c.Chunks[insertAt:])
+	c.Chunks[insertAt] = newChunk
+}
+
+// order gives the position of the chunk in a cartridge saved by the TIC-80: the banks come first, each with its
+// chunks in bankChunkOrder, then any chunks with no place in a bank, and finally the code
+func (chunk *Chunk) order() int {
+	for i, chunkType := range bankChunkOrder {
+		if chunk.Type == chunkType {
+			return chunk.Bank*len(bankChunkOrder) + i
+		}
+	}
+
+	order := CodeBanks * len(bankChunkOrder)
+
+	if chunk.Type == ChunkCode {
+		order += int(maxChunkType+1) * CodeBanks
+	} else {
+		order += int(chunk.Type) * CodeBanks
+	}
+
+	return order + chunk.Bank
+}
+
+// Code returns all the source code stored in the cartridge, joining the code chunks of every bank together
+func (c *Cart) Code() (string, error) {
+	codeChunks := make([]*Chunk, 0)
+
+	for _, chunk := range c.Chunks {
+		if chunk.Type == ChunkCode {
+			codeChunks = append(codeChunks, chunk)
+		}
+	}
+
+	if len(codeChunks) == 0 {
+		// old cartridges may have their code compressed instead
+		if zipped := c.Chunk(ChunkCodeZip, 0); zipped != nil {
+			return unzipCode(zipped.Data)
+		}
+		return "", nil
+	}
+
+	sort.SliceStable(codeChunks, func(i, j int) bool {
+		return codeChunks[i].Bank < codeChunks[j].Bank
+	})
+
+	var code bytes.Buffer
+	for _, chunk := range codeChunks {
+		code.Write(chunk.Data)
+	}
+
+	return string(bytes.TrimRight(code.Bytes(), "\x00")), nil
+}
+
+func unzipCode(data []byte) (string, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+
+	if err != nil {
+		return "", fmt.Errorf("cannot decompress the code of the cartridge: %w", err)
+	}
+	defer reader.Close()
+
+	code, err := ioutil.ReadAll(reader)
+
+	if err != nil {
+		return "", fmt.Errorf("cannot decompress the code of the cartridge: %w", err)
+	}
+
+	return string(bytes.TrimRight(code, "\x00")), nil
+}
+
+// SetCode replaces all the code in the cartridge with the given code, spreading it across as many banks as needed.
+// The new code chunks take the place of the old ones, so the rest of the cartridge is left untouched.
+func (c *Cart) SetCode(code string) error {
+	if len(code) > CodeBankSize*CodeBanks {
+		return fmt.Errorf("code is too large to fit in a cartridge (%d bytes, the limit is %d)", len(code), CodeBankSize*CodeBanks)
+	}
+
+	codeChunks := make([]*Chunk, 0)
+	for bank := 0; bank*CodeBankSize < len(code); bank++ {
+		end := (bank + 1) * CodeBankSize
+		if end > len(code) {
+			end = len(code)
+		}
+		codeChunks = append(codeChunks, &Chunk{
+			Type: ChunkCode,
+			Bank: bank,
+			Data: []byte(code[bank*CodeBankSize : end]),
+		})
+	}
+
+	// find where the old code was so the new code can be put in the same place
+	insertAt := -1
+	remaining := make([]*Chunk, 0, len(c.Chunks))
+
+	for _, chunk := range c.Chunks {
+		if chunk.Type == ChunkCode || chunk.Type == ChunkCodeZip {
+			if insertAt < 0 {
+				insertAt = len(remaining)
+			}
+			continue
+		}
+		remaining = append(remaining, chunk)
+	}
+
+	if insertAt < 0 {
+		insertAt = len(remaining)
+	}
+
+	chunks := make([]*Chunk, 0, len(remaining)+len(codeChunks))
+	chunks = append(chunks, remaining[:insertAt]...)
+	chunks = append(chunks, codeChunks...)
+	chunks = append(chunks, remaining[insertAt:]...)
+
+	c.Chunks = chunks
+
+	return nil
+}
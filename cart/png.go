@@ -0,0 +1,127 @@
+package cart
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io/ioutil"
+)
+
+// the TIC-80 stores a cartridge inside of a PNG image as a zlib compressed chunk of this type. The lowercase first
+// letter marks it as ancillary, so image viewers simply show the cover.
+const pngCartChunk = "caRt"
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// isPNG determines if the data is a PNG image rather than a binary cartridge
+func isPNG(data []byte) bool {
+	return bytes.HasPrefix(data, pngSignature)
+}
+
+// PNG serializes the cartridge as a PNG image of the given cover, with the compressed cartridge embedded in it
+func (c *Cart) PNG(cover image.Image) ([]byte, error) {
+	data, err := c.Bytes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed bytes.Buffer
+	writer, err := zlib.NewWriterLevel(&compressed, zlib.BestCompression)
+
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var encoded bytes.Buffer
+
+	if err := png.Encode(&encoded, cover); err != nil {
+		return nil, err
+	}
+
+	// the cartridge goes right before the IEND chunk that closes the image, which is always the last 12 bytes
+	imageData := encoded.Bytes()
+	iend := len(imageData) - 12
+
+	var result bytes.Buffer
+	result.Write(imageData[:iend])
+	_writePNGChunk(&result, pngCartChunk, compressed.Bytes())
+	result.Write(imageData[iend:])
+
+	return result.Bytes(), nil
+}
+
+// SavePNG serializes the cartridge as a PNG image of the given cover and writes it to the given path
+func (c *Cart) SavePNG(path string, cover image.Image) error {
+	data, err := c.PNG(cover)
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func _writePNGChunk(buffer *bytes.Buffer, chunkType string, data []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	buffer.Write(length[:])
+
+	// the checksum covers the type as well as the data
+	checksum := crc32.NewIEEE()
+	checksum.Write([]byte(chunkType))
+	checksum.Write(data)
+
+	buffer.WriteString(chunkType)
+	buffer.Write(data)
+
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], checksum.Sum32())
+	buffer.Write(crc[:])
+}
+
+// ParsePNG reads a cartridge out of a PNG image saved by the TIC-80 or by Cart.PNG
+func ParsePNG(data []byte) (*Cart, error) {
+	if !isPNG(data) {
+		return nil, errors.New("not a PNG image")
+	}
+
+	for offset := len(pngSignature); offset+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		chunkType := string(data[offset+4 : offset+8])
+		start := offset + 8
+
+		if length < 0 || start+length+4 > len(data) {
+			break
+		}
+
+		if chunkType == pngCartChunk {
+			reader, err := zlib.NewReader(bytes.NewReader(data[start : start+length]))
+			if err != nil {
+				return nil, err
+			}
+			defer reader.Close()
+
+			cartData, err := ioutil.ReadAll(reader)
+			if err != nil {
+				return nil, err
+			}
+
+			return Parse(cartData)
+		}
+
+		offset = start + length + 4
+	}
+
+	return nil, errors.New("the image does not have a cartridge embedded in it")
+}
@@ -0,0 +1,239 @@
+package cart
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A section describes how the data of a chunk is laid out in the text cartridge format, where it is written
+// as a block of hex rows inside of comments like
+//
+//	-- <TILES>
+//	-- 001:0123456789abcdef...
+//	-- </TILES>
+type section struct {
+	name      string
+	chunkType ChunkType
+	rows      int
+	rowSize   int
+	// 4-bit data like pixels are written with the low nibble of every byte first, so each hex digit is one value
+	flipNibbles bool
+}
+
+// sections lists every chunk that can be stored in a text cartridge, in the order the TIC-80 writes them
+var sections = []section{
+	{"TILES", ChunkTiles, 256, 32, true},
+	{"SPRITES", ChunkSprites, 256, 32, true},
+	{"MAP", ChunkMap, 136, 240, true},
+	{"WAVES", ChunkWaveform, 16, 16, true},
+	{"SFX", ChunkSamples, 64, 66, true},
+	{"PATTERNS", ChunkPatterns, 60, 192, true},
+	{"TRACKS", ChunkMusic, 8, 51, true},
+	{"FLAGS", ChunkFlags, 2, 256, true},
+	{"SCREEN", ChunkScreen, 136, 120, true},
+	{"PALETTE", ChunkPalette, 2, 48, false},
+}
+
+// matches the opening tag of a section like `-- <TILES>` or `// <MAP1>`, whatever the comment token is
+var reSectionStart = regexp.MustCompile(`^\s*(?:--|//|;;|#)?\s*<([A-Z]+?)(\d?)>\s*$`)
+
+// matches the closing tag of a section like `-- </TILES>`
+var reSectionEnd = regexp.MustCompile(`^\s*(?:--|//|;;|#)?\s*</([A-Z]+?)(\d?)>\s*$`)
+
+// matches a single row of data inside of a section like `-- 001:0123456789abcdef`
+var reSectionRow = regexp.MustCompile(`^\s*(?:--|//|;;|#)?\s*(\d+):([0-9a-fA-F]*)\s*$`)
+
+func findSection(name string) (section, bool) {
+	for _, s := range sections {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return section{}, false
+}
+
+// ParseText reads a cartridge from the text format, where the code comes first and the data of the cartridge
+// follows in comment blocks. Everything outside of the data sections is taken to be the code.
+func ParseText(text string) (*Cart, error) {
+	cart := New()
+	code := make([]string, 0)
+
+	lines := strings.Split(text, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		start := reSectionStart.FindStringSubmatch(lines[i])
+
+		if start == nil {
+			code = append(code, lines[i])
+			continue
+		}
+
+		s, known := findSection(start[1])
+		if !known {
+			// not one of ours, so it may very well be part of the code
+			code = append(code, lines[i])
+			continue
+		}
+
+		bank := 0
+		if start[2] != "" {
+			bank, _ = strconv.Atoi(start[2])
+		}
+
+		data := make([]byte, s.rows*s.rowSize)
+		closed := false
+
+		for i++; i < len(lines); i++ {
+			if end := reSectionEnd.FindStringSubmatch(lines[i]); end != nil && end[1] == start[1] {
+				closed = true
+				break
+			}
+
+			if strings.TrimSpace(lines[i]) == "" {
+				continue
+			}
+
+			row := reSectionRow.FindStringSubmatch(lines[i])
+			if row == nil {
+				return nil, fmt.Errorf("invalid row in the %s section (line %d): %s", s.name, i+1, lines[i])
+			}
+
+			if err := s.decodeRow(data, row[1], row[2]); err != nil {
+				return nil, fmt.Errorf("invalid row in the %s section (line %d): %w", s.name, i+1, err)
+			}
+		}
+
+		if !closed {
+			return nil, fmt.Errorf("the %s section is never closed", s.name)
+		}
+
+		if data = TrimTrailingZeros(data); len(data) > 0 {
+			cart.SetChunk(s.chunkType, bank, data)
+		}
+	}
+
+	if err := cart.SetCode(strings.TrimRight(strings.Join(code, "\n"), "\n") + "\n"); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+func (s section) decodeRow(data []byte, index string, hexDigits string) error {
+	row, err := strconv.Atoi(index)
+
+	if err != nil || row < 0 || row >= s.rows {
+		return fmt.Errorf("row %s is out of range, the section only has %d rows", index, s.rows)
+	}
+
+	if len(hexDigits) > s.rowSize*2 || len(hexDigits)%2 != 0 {
+		return fmt.Errorf("row %s must have at most %d hex digits, and an even amount of them", index, s.rowSize*2)
+	}
+
+	if s.flipNibbles {
+		hexDigits = flipNibbles(hexDigits)
+	}
+
+	decoded, err := hex.DecodeString(hexDigits)
+
+	if err != nil {
+		return err
+	}
+
+	copy(data[row*s.rowSize:], decoded)
+
+	return nil
+}
+
+// flipNibbles swaps every pair of hex digits, which converts between the order of the nibbles in memory
+// and the order of the values they represent
+func flipNibbles(hexDigits string) string {
+	flipped := []byte(hexDigits)
+	for i := 0; i+1 < len(flipped); i += 2 {
+		flipped[i], flipped[i+1] = flipped[i+1], flipped[i]
+	}
+	return string(flipped)
+}
+
+// Text serializes the cartridge into the text format, with the code first and the data sections after it
+// using the given token to start each comment. Chunks that have no place in the text format are left out.
+func (c *Cart) Text(commentPrefix string) (string, error) {
+	code, err := c.Code()
+
+	if err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+
+	text.WriteString(strings.TrimRight(code, "\n"))
+	text.WriteString("\n")
+
+	for bank := 0; bank < CodeBanks; bank++ {
+		for _, s := range sections {
+			chunk := c.Chunk(s.chunkType, bank)
+
+			if chunk == nil || len(TrimTrailingZeros(chunk.Data)) == 0 {
+				continue
+			}
+
+			tag := s.name
+			if bank > 0 {
+				tag += strconv.Itoa(bank)
+			}
+
+			fmt.Fprintf(&text, "\n%s <%s>\n", commentPrefix, tag)
+			s.writeRows(&text, commentPrefix, chunk.Data)
+			fmt.Fprintf(&text, "%s </%s>\n", commentPrefix, tag)
+		}
+	}
+
+	return text.String(), nil
+}
+
+func (s section) writeRows(text *strings.Builder, commentPrefix string, data []byte) {
+	emptyRow := make([]byte, s.rowSize)
+
+	for row := 0; row < s.rows && row*s.rowSize < len(data); row++ {
+		rowData := make([]byte, s.rowSize)
+		copy(rowData, data[row*s.rowSize:])
+
+		// just like the TIC-80, leave out rows that are completely empty
+		if bytes.Equal(rowData, emptyRow) {
+			continue
+		}
+
+		hexDigits := hex.EncodeToString(rowData)
+		if s.flipNibbles {
+			hexDigits = flipNibbles(hexDigits)
+		}
+
+		fmt.Fprintf(text, "%s %03d:%s\n", commentPrefix, row, hexDigits)
+	}
+}
+
+// SectionChunks returns all the chunks of the cartridge that can be stored as data sections in the text format
+func (c *Cart) SectionChunks() []*Chunk {
+	chunks := make([]*Chunk, 0)
+
+	for _, chunk := range c.Chunks {
+		for _, s := range sections {
+			if chunk.Type == s.chunkType {
+				chunks = append(chunks, chunk)
+				break
+			}
+		}
+	}
+
+	return chunks
+}
+
+// TrimTrailingZeros returns the data without any zeroes at the end, which is how the TIC-80 stores chunks
+// to save space in the cartridge
+func TrimTrailingZeros(data []byte) []byte {
+	return bytes.TrimRight(data, "\x00")
+}
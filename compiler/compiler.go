@@ -1,8 +1,8 @@
 package compiler
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"path"
 	"strings"
 
@@ -31,6 +31,109 @@ type LangService interface {
 	GetMacroStringDeclaration(line string) (name string, contents string, err error)
 
 	SubstituteDefines(line string, defines map[string]string) string
+
+	CodeGenerator
+
+	// write the lines of a function that decodes the strings made by blob.Pack back into a list of bytes
+	BlobDecoder() []string
+	// write a call to the function from BlobDecoder that decodes the given string
+	FormatBlobDecode(packed string) string
+}
+
+// A CodeGenerator is the part of a LangService that writes code of its own, for the values that the assets of the
+// project and the macros turn into code
+type CodeGenerator interface {
+	// write a value built by the literal package (lists, maps, strings, numbers...) as a literal of the language
+	FormatLiteral(value interface{}) string
+	// write a top-level declaration that makes the given code available to the whole program under the given name
+	FormatDeclaration(name string, value string) string
+}
+
+// An ExportStripper is a LangService whose export declarations carry syntax that only makes sense across files,
+// and which must be removed from the line once all the files are stitched together into one
+type ExportStripper interface {
+	// remove the export syntax from a line, assuming it really is an export declaration. Nothing may be left of the line
+	// if it only exports names declared elsewhere.
+	StripExportKeyword(line string) (string, error)
+}
+
+// A BlockStripper is a LangService whose comments and strings can span several lines, so that a line can only be
+// stripped knowing what the lines before it left open
+type BlockStripper interface {
+	// strip a line the way StripUnimportant does, given the comment or string that is still open from the lines before
+	// it (nil if there is none), returning the one that is still open at the end of the line
+	StripBlocks(line string, open *OpenBlock) (string, *OpenBlock)
+}
+
+// An OpenBlock is a comment or a string that is still open at the end of a line, and carries on into the next one
+type OpenBlock struct {
+	// the text that closes it, like ]] or */
+	Closing string
+	// whether it is a comment, which is left out of the output, rather than a string, which is kept as it is
+	IsComment bool
+	// how many comments are open inside of each other, for the languages where block comments nest
+	Depth int
+}
+
+// A TreeShaker is a LangService that can tell where the top level declarations of the code end, so that the exported
+// ones that nothing uses can be left out
+type TreeShaker interface {
+	// determine if a line continues the top level statement made of the lines before it, like the indented lines of a
+	// function and the line that closes it
+	ContinuesDeclaration(statement []string, line string) bool
+}
+
+// A BlobReport describes a file that was packed into the code by a #blob macro
+type BlobReport struct {
+	Name string
+	Path string
+	// RawSize is the size of the file in bytes
+	RawSize int
+	// PackedSize is the length of the string it was packed into
+	PackedSize int
+}
+
+// A ShakeReport describes the code that was left out of a file because nothing used it
+type ShakeReport struct {
+	Path string
+	// Symbols are the exported symbols whose declarations were left out
+	Symbols []string
+	// Bytes is the size of the code that was left out
+	Bytes int
+}
+
+// A SizeReport tells how much of the output came from a file
+type SizeReport struct {
+	// Path is the file the code came from, which is empty for the code generated from the assets
+	Path string
+	// Size is the number of bytes of the output, which is what the TIC-80 limits
+	Size int
+}
+
+// A Minifier is a LangService that can shrink the code of the whole program once it has been stitched together,
+// without changing what it does
+type Minifier interface {
+	// shrink the code, which does not include the prelude
+	Minify(code string) (string, error)
+}
+
+// A Mangler is a LangService that can rename the identifiers of the whole program to shorter ones once it has been
+// stitched together
+type Mangler interface {
+	// rename the local variables, function parameters and top level symbols declared in the code (which does not
+	// include the prelude) to shorter names, leaving the reserved names as they are
+	Mangle(code string, reserved map[string]bool) (string, error)
+}
+
+// Options are the optional steps of the compilation
+type Options struct {
+	// Minify shrinks the output with the Minifier of the LangService
+	Minify bool
+	// Mangle renames the identifiers in the output with the Mangler of the LangService, before it is minified
+	Mangle bool
+	// TreeShake leaves out the exported declarations that are never imported or used, before anything else is done
+	// to the output
+	TreeShake bool
 }
 
 // ImportData contains information about the imports for a particular file
@@ -39,14 +142,29 @@ type ImportData struct {
 	Path    string
 }
 
-// Compiler is the central control struct that reads input files and stitches them together into the output file
+// Compiler is the central control struct that reads input files and stitches them together into the output code
 type Compiler struct {
 	LangService
-	outputFile           *os.File
+	output               *strings.Builder
 	directory            string
 	fileStack            *FileStack
 	alreadyImportedFiles map[string]*SourceFile
 	defines              map[string]string
+	options              Options
+	generatedCode        []string
+	blobs                []BlobReport
+	removed              []ShakeReport
+
+	// the length of the prelude at the start of the output
+	preludeLength int
+	// the file that the lines being written come from, which is empty for the generated code
+	currentPath string
+	// the file each line of the output after the prelude was written from
+	lineFiles []string
+	// the symbols exported by the lines of the output that are export declarations, by the index of the line
+	exportLines map[int][]string
+	// the lines of the output after the prelude that carry on a string from the line before them, by their index
+	continuedLines map[int]bool
 
 	conditionStack        *stack.Stack
 	disabledNestedIfCount int
@@ -56,9 +174,9 @@ type Compiler struct {
 func NewCompiler(
 	langservice LangService,
 	mainfile string,
-	outputfilename string,
 	directory string,
 	defines map[string]string,
+	options Options,
 ) *Compiler {
 	// the main file is guaranteed to exist
 	mainSourceFile, _ := newSourceFile(mainfile)
@@ -66,48 +184,228 @@ func NewCompiler(
 	fileStack := NewFileStack(1)
 	fileStack.Push(mainSourceFile)
 
-	outputFile, _ := os.Create(outputfilename)
+	// the define macros of the code must not leak into the defines that were passed in, since those are used again
+	// for every compilation in watch mode
+	ownDefines := make(map[string]string, len(defines))
+	for name, value := range defines {
+		ownDefines[name] = value
+	}
 
 	return &Compiler{
 		LangService:          langservice,
-		outputFile:           outputFile,
+		output:               &strings.Builder{},
 		directory:            directory,
 		fileStack:            fileStack,
 		alreadyImportedFiles: make(map[string]*SourceFile),
-		defines:              defines,
+		defines:              ownDefines,
+		options:              options,
+		exportLines:          make(map[int][]string),
+		continuedLines:       make(map[int]bool),
 
 		conditionStack:        stack.NewStack(10),
 		disabledNestedIfCount: 0,
 	}
 }
 
-// Start starts the compilation process
+// Start starts the compilation process. The resulting code can be fetched with Output once it is done
 func (c *Compiler) Start() error {
-	defer func() {
-		c.outputFile.Close()
-		c.outputFile = nil
-	}()
 	if err := c._writePrelude(); err != nil {
 		return err
 	}
+	c.preludeLength = c.output.Len()
+	c._writeLine(c.generatedCode...)
 	if err := c._processFile(); err != nil {
 		return err
 	}
+	if c.options.TreeShake {
+		if err := c._treeShake(); err != nil {
+			return err
+		}
+	}
+	if c.options.Mangle {
+		if err := c._mangle(); err != nil {
+			return err
+		}
+	}
+	if c.options.Minify {
+		return c._minify()
+	}
 	return nil
 }
 
+// _minify shrinks everything that was written after the prelude, which has to be kept as it is for the TIC-80 to read
+func (c *Compiler) _minify() error {
+	minifier, ok := c.LangService.(Minifier)
+
+	if !ok {
+		return errors.New("minification is not supported for this language yet")
+	}
+
+	lines := c._outputLines()
+	var code strings.Builder
+	lineFiles := []string{}
+
+	// each run of lines from the same file is minified on its own, so that the size of every file can still be told
+	// apart in the minified code
+	for start := 0; start < len(lines); {
+		end := start + 1
+		for end < len(lines) && c.lineFiles[end] == c.lineFiles[start] {
+			end++
+		}
+
+		minified, err := minifier.Minify(strings.Join(lines[start:end], "\n") + "\n")
+
+		if err != nil {
+			return fmt.Errorf("Error minifying the code:\n%w", err)
+		}
+
+		if minified != "" && !strings.HasSuffix(minified, "\n") {
+			minified += "\n"
+		}
+
+		code.WriteString(minified)
+		for i := strings.Count(minified, "\n"); i > 0; i-- {
+			lineFiles = append(lineFiles, c.lineFiles[start])
+		}
+
+		start = end
+	}
+
+	// the lines no longer line up with the export declarations they came from, but nothing needs them after this
+	c.lineFiles, c.exportLines, c.continuedLines = lineFiles, make(map[int][]string), make(map[int]bool)
+	c._replaceAfterPrelude(code.String())
+
+	return nil
+}
+
+// _mangle renames the identifiers in everything that was written after the prelude. Only the names the TIC-80 itself
+// uses are kept. The symbols exported by the files can be renamed like any other, since every file ends up in the
+// same chunk, so the code that uses them is renamed along with them.
+func (c *Compiler) _mangle() error {
+	mangler, ok := c.LangService.(Mangler)
+
+	if !ok {
+		return errors.New("identifier mangling is not supported for this language yet")
+	}
+
+	reserved := make(map[string]bool)
+	for _, name := range TICNames {
+		reserved[name] = true
+	}
+
+	code := c.output.String()
+	mangled, err := mangler.Mangle(code[c.preludeLength:], reserved)
+
+	if err != nil {
+		return fmt.Errorf("Error mangling the code:\n%w", err)
+	}
+
+	c._replaceAfterPrelude(mangled)
+
+	return nil
+}
+
+// _allFiles returns every file of the program, once they have all been processed
+func (c *Compiler) _allFiles() []*SourceFile {
+	// the main file is the only one left on the stack
+	files := []*SourceFile{c.fileStack.Peek()}
+	for _, file := range c.alreadyImportedFiles {
+		files = append(files, file)
+	}
+	return files
+}
+
+// _outputLines splits everything that was written after the prelude into its lines
+func (c *Compiler) _outputLines() []string {
+	code := c.output.String()[c.preludeLength:]
+
+	if code == "" {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSuffix(code, "\n"), "\n")
+}
+
+// _replaceAfterPrelude replaces everything that was written after the prelude with the given code
+func (c *Compiler) _replaceAfterPrelude(code string) {
+	prelude := c.output.String()[:c.preludeLength]
+
+	c.output.Reset()
+	c.output.WriteString(prelude)
+	c.output.WriteString(code)
+}
+
+// AddGeneratedCode adds lines of code made from the assets of the project (like the entities of a map), which are
+// written right after the prelude so that the code of every file can use them
+func (c *Compiler) AddGeneratedCode(lines ...string) {
+	c.generatedCode = append(c.generatedCode, lines...)
+}
+
+// Define adds a define as if it was declared before the first line of the main file, unless there already is one
+// with the same name. This lets the defines passed in on the command line take precedence.
+func (c *Compiler) Define(name string, value string) {
+	if _, exists := c.defines[name]; !exists {
+		c.defines[name] = value
+	}
+}
+
+// Blobs returns the files that were packed into the code by #blob macros, in the order they were found
+func (c Compiler) Blobs() []BlobReport {
+	return c.blobs
+}
+
+// Removed returns the code that was left out of each file by tree shaking, in the order the files were written
+func (c Compiler) Removed() []ShakeReport {
+	return c.removed
+}
+
+// Sizes returns how many bytes of the output came from each file, in the order the files were written. The prelude
+// counts towards the main file.
+func (c Compiler) Sizes() []SizeReport {
+	reports := []SizeReport{{Path: c.fileStack.Peek().path, Size: c.preludeLength}}
+	indexes := map[string]int{reports[0].Path: 0}
+
+	for i, line := range c._outputLines() {
+		path := c.lineFiles[i]
+		index, exists := indexes[path]
+		if !exists {
+			index = len(reports)
+			indexes[path] = index
+			reports = append(reports, SizeReport{Path: path})
+		}
+		reports[index].Size += len(line) + 1
+	}
+
+	return reports
+}
+
+// Output returns all the code that has been stitched together by Start
+func (c Compiler) Output() string {
+	return c.output.String()
+}
+
 func (c Compiler) _write(values ...string) {
 	for _, s := range values {
-		c.outputFile.WriteString(s)
+		c.output.WriteString(s)
 	}
 }
 
-func (c Compiler) _writeLine(lines ...string) {
+func (c *Compiler) _writeLine(lines ...string) {
 	for _, line := range lines {
-		c.outputFile.WriteString(line + "\n")
+		c.output.WriteString(line + "\n")
+		// generated code can span several lines
+		for range strings.Split(line, "\n") {
+			c.lineFiles = append(c.lineFiles, c.currentPath)
+		}
 	}
 }
 
+// _addExports exports the symbols that the next line to be written declares from the file
+func (c *Compiler) _addExports(file *SourceFile, symbols []string) {
+	file.addExportedSymbols(symbols)
+	c.exportLines[len(c.lineFiles)] = symbols
+}
+
 func (c *Compiler) _pushFile(sourcefile *SourceFile) {
 	c.fileStack.Push(sourcefile)
 	c.alreadyImportedFiles[sourcefile.path] = sourcefile
@@ -139,13 +437,17 @@ func (c *Compiler) _writePrelude() error {
 
 func (c *Compiler) _processFile() error {
 	currentFile := c.fileStack.Peek()
+	// the comment or string that the lines so far have left open
+	var open *OpenBlock
 
 	for i, line := range currentFile.lines() {
 		lineNumber := i + 1
 		langService := c.LangService
+		// set again for every line, since an import in the line before may have changed it
+		c.currentPath = currentFile.path
 
 		// check if the line is a macro
-		if langService.IsLineMacro(line) {
+		if open == nil && langService.IsLineMacro(line) {
 			macroType := langService.GetMacroType(line)
 			err := c.handleMacro(macroType, line)
 			if err != nil {
@@ -158,7 +460,21 @@ func (c *Compiler) _processFile() error {
 			continue
 		}
 
-		line = langService.StripUnimportant(line)
+		// the lines in the middle of a string are part of its text, and are written as they are
+		inString := open != nil && !open.IsComment
+
+		if stripper, ok := langService.(BlockStripper); ok {
+			line, open = stripper.StripBlocks(line, open)
+		} else {
+			line = langService.StripUnimportant(line)
+		}
+
+		if inString {
+			c.continuedLines[len(c.lineFiles)] = true
+			c._writeLine(line)
+			continue
+		}
+
 		// NOTE that it is important that we substitute the defines AFTER we strip the unimportant spaces.
 		// This allows us to easily preserve any player-facing strings from mangling by putting them in a
 		// #string define
@@ -182,7 +498,22 @@ func (c *Compiler) _processFile() error {
 
 		if langService.IsExportDeclaration(line) {
 			exportedSymbols := langService.GetExportDeclarations(line)
-			currentFile.addExportedSymbols(exportedSymbols)
+
+			if stripper, ok := langService.(ExportStripper); ok {
+				stripped, err := stripper.StripExportKeyword(line)
+				if err != nil {
+					return fmt.Errorf("Error processing file '%s' (line %d):\n%w", currentFile.path, lineNumber, err)
+				}
+				line = stripped
+			}
+
+			if len(strings.TrimSpace(line)) == 0 {
+				// a list like 'export { A, B }' exports names declared elsewhere, and leaves no code behind
+				currentFile.addExportedSymbols(exportedSymbols)
+				continue
+			}
+
+			c._addExports(currentFile, exportedSymbols)
 		}
 
 		c._writeLine(line)
@@ -3,6 +3,12 @@ package compiler
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/novemberisms/ticc/blob"
+	"github.com/novemberisms/ticc/literal"
 )
 
 // MacroType is an enum that specifies one of the available macro types supported by the ticc code compiler
@@ -38,6 +44,15 @@ const (
 	MacroTypeElse
 	// MacroTypeEndIf denotes an endif marker that terminates the conditional compilation mode
 	MacroTypeEndIf
+	// MacroTypeData denotes a #data macro, which reads a .csv or .json file and declares its contents as a literal
+	// of the language in place of the macro
+	MacroTypeData
+	// MacroTypeEmbed denotes an #embed macro, which reads a text file and defines its contents as a string literal
+	// of the language
+	MacroTypeEmbed
+	// MacroTypeBlob denotes a #blob macro, which packs a binary file into a string and declares the bytes it decodes
+	// back into in place of the macro
+	MacroTypeBlob
 )
 
 func (m MacroType) String() string {
@@ -54,6 +69,12 @@ func (m MacroType) String() string {
 		return "else"
 	case MacroTypeEndIf:
 		return "endif"
+	case MacroTypeData:
+		return "data"
+	case MacroTypeEmbed:
+		return "embed"
+	case MacroTypeBlob:
+		return "blob"
 	case MacroTypeUnknown:
 		fallthrough
 	default:
@@ -80,6 +101,12 @@ func (c *Compiler) handleMacro(macroType MacroType, line string) error {
 		return c._handleElseMacro(line)
 	case MacroTypeEndIf:
 		return c._handleEndIfMacro(line)
+	case MacroTypeData:
+		return c._handleDataMacro(line)
+	case MacroTypeEmbed:
+		return c._handleEmbedMacro(line)
+	case MacroTypeBlob:
+		return c._handleBlobMacro(line)
 	}
 	return errors.New("unknown macro")
 }
@@ -177,6 +204,83 @@ func (c *Compiler) _handleStringMacro(line string) error {
 	return nil
 }
 
+func (c *Compiler) _handleDataMacro(line string) error {
+	name, dataPath, err := c.LangService.GetMacroStringDeclaration(line)
+
+	if err != nil {
+		return errors.New("invalid format for data macro. must be #data [NAME] path/to/file.csv")
+	}
+
+	// data files are found relative to the project directory, just like imports
+	dataFile := path.Join(c.directory, strings.TrimSpace(dataPath))
+	value, err := literal.ParseFile(dataFile)
+
+	if err != nil {
+		return fmt.Errorf("Error reading data file '%s':\n%w", dataFile, err)
+	}
+
+	// the name is exported like any other top level declaration, so that other files can import it
+	c._addExports(c.fileStack.Peek(), []string{name})
+	c._writeLine(c.LangService.FormatDeclaration(name, c.LangService.FormatLiteral(value)))
+
+	return nil
+}
+
+func (c *Compiler) _handleEmbedMacro(line string) error {
+	name, textPath, err := c.LangService.GetMacroStringDeclaration(line)
+
+	if err != nil {
+		return errors.New("invalid format for embed macro. must be #embed [NAME] path/to/file.txt")
+	}
+
+	textFile := path.Join(c.directory, strings.TrimSpace(textPath))
+	text, err := ioutil.ReadFile(textFile)
+
+	if err != nil {
+		return fmt.Errorf("Error reading embedded file '%s':\n%w", textFile, err)
+	}
+
+	// windows line endings become plain newlines, and the newline that most editors add at the end of the file is
+	// left out
+	contents := strings.ReplaceAll(string(text), "\r\n", "\n")
+	contents = strings.TrimSuffix(contents, "\n")
+
+	// like a string macro, the literal is only substituted in after the line has been stripped of comments and
+	// whitespace, so it reaches the output exactly as it is escaped here
+	c._newDefine(name, c.LangService.FormatLiteral(contents))
+
+	return nil
+}
+
+func (c *Compiler) _handleBlobMacro(line string) error {
+	name, blobPath, err := c.LangService.GetMacroStringDeclaration(line)
+
+	if err != nil {
+		return errors.New("invalid format for blob macro. must be #blob [NAME] path/to/file.bin")
+	}
+
+	blobFile := path.Join(c.directory, strings.TrimSpace(blobPath))
+	data, err := ioutil.ReadFile(blobFile)
+
+	if err != nil {
+		return fmt.Errorf("Error reading blob file '%s':\n%w", blobFile, err)
+	}
+
+	// the decoder is only written once, right before the first blob that needs it
+	if len(c.blobs) == 0 {
+		c._writeLine(c.LangService.BlobDecoder()...)
+	}
+
+	packed := blob.Pack(data)
+
+	// like #data, the name is exported so that other files can import it
+	c._addExports(c.fileStack.Peek(), []string{name})
+	c._writeLine(c.LangService.FormatDeclaration(name, c.LangService.FormatBlobDecode(packed)))
+	c.blobs = append(c.blobs, BlobReport{name, blobFile, len(data), len(packed)})
+
+	return nil
+}
+
 func (c *Compiler) _handleIfMacro(line string) error {
 
 	condition, err := c._evaluateConditional(line)
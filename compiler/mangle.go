@@ -0,0 +1,90 @@
+package compiler
+
+import (
+	"sort"
+)
+
+// TICNames are the names that the TIC-80 gives a meaning to, which must never be renamed: the callbacks it calls, the
+// class it creates in wren, and the functions of its API
+var TICNames = []string{
+	"TIC", "SCN", "OVR", "BDR", "BOOT", "MENU", "Game",
+	"btn", "btnp", "circ", "circb", "clip", "cls", "elli", "ellib", "exit", "fft", "ffts", "fget", "font", "fset",
+	"key", "keyp", "line", "map", "memcpy", "memset", "mget", "mouse", "mset", "music", "paint", "peek", "peek1",
+	"peek2", "peek4", "pix", "pmem", "poke", "poke1", "poke2", "poke4", "print", "rect", "rectb", "reset", "sfx",
+	"spr", "sync", "textri", "time", "trace", "tri", "trib", "tstamp", "ttri", "vbank",
+}
+
+const (
+	lowerLetters = "abcdefghijklmnopqrstuvwxyz"
+	upperLetters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// the characters a name can go on with after its first letter
+	nameCharacters = lowerLetters + upperLetters + "0123456789"
+)
+
+// ShortNames picks a new name for each of the identifiers, given how many times each of them is used. The ones used
+// the most get the shortest names, and the new names start with a letter of the same case as the old ones, since
+// some languages (like wren) read names differently depending on it. None of the new names are taken, and identifiers
+// that no shorter name can be found for are left out.
+func ShortNames(counts map[string]int, taken map[string]bool) map[string]string {
+	identifiers := make([]string, 0, len(counts))
+	for identifier := range counts {
+		identifiers = append(identifiers, identifier)
+	}
+
+	// sorting by name as well keeps the names the same from one build to the next
+	sort.Slice(identifiers, func(i, j int) bool {
+		a, b := identifiers[i], identifiers[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		return a < b
+	})
+
+	names := make(map[string]string)
+	// the index of the next name to try for identifiers that start with a lowercase and an uppercase letter
+	next := map[bool]int{}
+
+	for _, identifier := range identifiers {
+		upper := identifier[0] >= 'A' && identifier[0] <= 'Z'
+
+		name := _shortName(next[upper], upper)
+		for taken[name] {
+			next[upper]++
+			name = _shortName(next[upper], upper)
+		}
+
+		if len(name) >= len(identifier) {
+			continue
+		}
+
+		names[identifier] = name
+		next[upper]++
+	}
+
+	return names
+}
+
+// _shortName finds the name at the given index in the list of all names ordered from the shortest to the longest
+func _shortName(index int, upper bool) string {
+	first := lowerLetters
+	if upper {
+		first = upperLetters
+	}
+
+	// find the length of the name, and its index among the names of that length
+	length, count := 1, len(first)
+	for index >= count {
+		index -= count
+		length++
+		count *= len(nameCharacters)
+	}
+
+	name := make([]byte, length)
+	for i := length - 1; i > 0; i-- {
+		name[i] = nameCharacters[index%len(nameCharacters)]
+		index /= len(nameCharacters)
+	}
+	name[0] = first[index]
+
+	return string(name)
+}
@@ -0,0 +1,61 @@
+package compiler_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/moonlang"
+	"github.com/novemberisms/ticc/wrenlang"
+)
+
+func TestMangleExportedSymbols(t *testing.T) {
+	tests := []struct {
+		name        string
+		langService compiler.LangService
+		main        string
+		files       map[string]string
+		exported    string
+		kept        []string
+	}{
+		{
+			name:        "moonscript",
+			langService: moonlang.MoonscriptLanguageService{},
+			main:        "main.moon",
+			files: map[string]string{
+				"main.moon":   "import playerSpeed from require \"player\"\nexport TIC = ->\n  cls playerSpeed\n",
+				"player.moon": "playerSpeed = 2\n",
+			},
+			exported: "playerSpeed",
+			kept:     []string{"TIC", "cls"},
+		},
+		{
+			name:        "wren",
+			langService: wrenlang.WrenLanguageService{},
+			main:        "main.wren",
+			files: map[string]string{
+				"main.wren":   "import \"player\" for PlayerSpeed\nclass Game is TIC {\n  TIC() {\n    TIC.cls(PlayerSpeed)\n  }\n}\n",
+				"player.wren": "var PlayerSpeed = 2\n",
+			},
+			exported: "PlayerSpeed",
+			kept:     []string{"Game", "TIC", "cls"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			comp := _compile(t, test.langService, compiler.Options{Mangle: true}, test.main, test.files)
+			output := comp.Output()
+
+			if regexp.MustCompile(`\b` + test.exported + `\b`).MatchString(output) {
+				t.Errorf("expected %s to be renamed everywhere:\n%s", test.exported, output)
+			}
+
+			for _, name := range test.kept {
+				if !regexp.MustCompile(`\b` + name + `\b`).MatchString(output) {
+					t.Errorf("expected %s to be kept:\n%s", name, output)
+				}
+			}
+		})
+	}
+}
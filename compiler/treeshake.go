@@ -0,0 +1,161 @@
+package compiler
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// a top level declaration of exported symbols, as the lines of the output it takes up
+type declaration struct {
+	start   int
+	end     int
+	symbols []string
+	// matches any code that uses one of the symbols
+	usage *regexp.Regexp
+}
+
+// _treeShake leaves out the top level declarations whose exported symbols are never imported, and never used by any
+// of the code that is kept. The code is split into top level statements, and the ones that start with an export
+// declaration are the declarations. All the other statements are kept, along with the callbacks of the TIC-80.
+// Names are only looked for as whole words, so a declaration is kept if its name shows up anywhere else in the code
+// that is kept, even in a string.
+func (c *Compiler) _treeShake() error {
+	shaker, ok := c.LangService.(TreeShaker)
+
+	if !ok {
+		return errors.New("tree shaking is not supported for this language yet")
+	}
+
+	lines := c._outputLines()
+
+	declarations := []*declaration{}
+	// the lines that are not part of any declaration, which are always kept
+	var roots strings.Builder
+
+	for i := 0; i < len(lines); {
+		// the top level statement that starts on this line
+		end := i + 1
+		// a line in the middle of a string always belongs to the statement the string started in, whatever the line
+		// looks like
+		for end < len(lines) && c.lineFiles[end] == c.lineFiles[i] &&
+			(c.continuedLines[end] || shaker.ContinuesDeclaration(lines[i:end], lines[end])) {
+			end++
+		}
+
+		symbols, isExport := c.exportLines[i]
+
+		if !isExport || len(symbols) == 0 {
+			roots.WriteString(strings.Join(lines[i:end], "\n") + "\n")
+		} else {
+			declarations = append(declarations, &declaration{
+				start:   i,
+				end:     end,
+				symbols: symbols,
+				usage:   _usagePattern(symbols),
+			})
+		}
+
+		i = end
+	}
+
+	// the symbols that are used from outside of the code
+	used := make(map[string]bool)
+	for _, name := range TICNames {
+		used[name] = true
+	}
+	for _, file := range c._allFiles() {
+		for _, symbol := range file.importedSymbols {
+			used[symbol] = true
+		}
+	}
+
+	kept := make([]bool, len(declarations))
+	// the code whose usages have not been looked for yet
+	queue := []string{roots.String()}
+
+	for i, d := range declarations {
+		for _, symbol := range d.symbols {
+			if used[symbol] && !kept[i] {
+				kept[i] = true
+				queue = append(queue, strings.Join(lines[d.start:d.end], "\n"))
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		code := queue[0]
+		queue = queue[1:]
+
+		for i, d := range declarations {
+			if !kept[i] && d.usage.MatchString(code) {
+				kept[i] = true
+				queue = append(queue, strings.Join(lines[d.start:d.end], "\n"))
+			}
+		}
+	}
+
+	// leave out the declarations that were not kept
+	removedLines := make([]bool, len(lines))
+	reports := make(map[string]*ShakeReport)
+
+	for i, d := range declarations {
+		if kept[i] {
+			continue
+		}
+
+		path := c.lineFiles[d.start]
+		report, exists := reports[path]
+		if !exists {
+			report = &ShakeReport{Path: path}
+			reports[path] = report
+		}
+
+		report.Symbols = append(report.Symbols, d.symbols...)
+		for line := d.start; line < d.end; line++ {
+			removedLines[line] = true
+			report.Bytes += len(lines[line]) + 1
+		}
+	}
+
+	// the reports are in the order their files were first written
+	for _, path := range c.lineFiles {
+		if report, exists := reports[path]; exists {
+			c.removed = append(c.removed, *report)
+			delete(reports, path)
+		}
+	}
+
+	var code strings.Builder
+	lineFiles := []string{}
+	exportLines := make(map[int][]string)
+	continuedLines := make(map[int]bool)
+
+	for i, line := range lines {
+		if removedLines[i] {
+			continue
+		}
+		if symbols, isExport := c.exportLines[i]; isExport {
+			exportLines[len(lineFiles)] = symbols
+		}
+		if c.continuedLines[i] {
+			continuedLines[len(lineFiles)] = true
+		}
+		code.WriteString(line + "\n")
+		lineFiles = append(lineFiles, c.lineFiles[i])
+	}
+
+	c.lineFiles, c.exportLines, c.continuedLines = lineFiles, exportLines, continuedLines
+	c._replaceAfterPrelude(code.String())
+
+	return nil
+}
+
+// _usagePattern matches code that uses any of the symbols as a whole word
+func _usagePattern(symbols []string) *regexp.Regexp {
+	quoted := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		quoted[i] = regexp.QuoteMeta(symbol)
+	}
+	return regexp.MustCompile(`(?:^|\W)(?:` + strings.Join(quoted, "|") + `)(?:\W|$)`)
+}
@@ -0,0 +1,79 @@
+package compiler_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/lualang"
+	"github.com/novemberisms/ticc/wrenlang"
+)
+
+// _compile compiles a project made of the given files, by their names, starting from the main one
+func _compile(t *testing.T, langService compiler.LangService, options compiler.Options, main string, files map[string]string) *compiler.Compiler {
+	dir, err := ioutil.TempDir("", "ticc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, code := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	comp := compiler.NewCompiler(langService, filepath.Join(dir, main), dir, map[string]string{}, options)
+	if err := comp.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return comp
+}
+
+func TestTreeShakeMultiLineStrings(t *testing.T) {
+	tests := []struct {
+		name        string
+		langService compiler.LangService
+		file        string
+		code        string
+		kept        string
+	}{
+		{
+			name:        "lua long strings",
+			langService: lualang.LuaLanguageService{},
+			file:        "main.lua",
+			code:        "HELP = [[\nPress Z\nto jump\n]]\nfunction TIC()\n  cls(0)\nend\n",
+			kept:        "cls(0)",
+		},
+		{
+			name:        "wren raw strings",
+			langService: wrenlang.WrenLanguageService{},
+			file:        "main.wren",
+			code:        "var HELP = \"\"\"\nPress Z\nto jump\n\"\"\"\nclass Game is TIC {\n  TIC() {\n    TIC.cls(0)\n  }\n}\n",
+			kept:        "TIC.cls(0)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			comp := _compile(t, test.langService, compiler.Options{TreeShake: true}, test.file, map[string]string{test.file: test.code})
+			output := comp.Output()
+
+			if strings.Contains(output, "Press Z") || strings.Contains(output, "to jump") {
+				t.Errorf("the lines of the string were left behind:\n%s", output)
+			}
+
+			if !strings.Contains(output, test.kept) {
+				t.Errorf("expected %q to be kept:\n%s", test.kept, output)
+			}
+
+			removed := comp.Removed()
+			if len(removed) != 1 || strings.Join(removed[0].Symbols, ",") != "HELP" {
+				t.Errorf("expected HELP to be removed, got %v", removed)
+			}
+		})
+	}
+}
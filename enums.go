@@ -1,24 +1,86 @@
 package main
 
+import "strings"
+
 // Language is an enum with a string underlying type for the supported languages
 type Language string
 
 const (
-	lua  Language = "lua"
-	wren Language = "wren"
-	moon Language = "moon"
-	auto Language = "auto"
+	lua      Language = "lua"
+	wren     Language = "wren"
+	moon     Language = "moon"
+	js       Language = "js"
+	fennel   Language = "fnl"
+	squirrel Language = "nut"
+	ruby     Language = "rb"
+	python   Language = "py"
+	auto     Language = "auto"
 )
 
-func isSupportedLanguage(lang Language) bool {
-	if lang == lua {
-		return true
+// commentPrefix returns the token that starts a single line comment in the language
+func (lang Language) commentPrefix() string {
+	switch lang {
+	case wren, js, squirrel:
+		return "//"
+	case fennel:
+		return ";;"
+	case ruby, python:
+		return "#"
+	default:
+		return "--"
 	}
-	if lang == wren {
-		return true
+}
+
+// scriptName returns the name the TIC-80 uses for the language in the 'script:' tag of the prelude
+func (lang Language) scriptName() string {
+	switch lang {
+	case fennel:
+		return "fennel"
+	case squirrel:
+		return "squirrel"
+	case ruby:
+		return "ruby"
+	case python:
+		return "python"
+	default:
+		return string(lang)
 	}
-	if lang == moon {
+}
+
+// languageFromScriptName finds the language given the name in the 'script:' tag of a prelude
+func languageFromScriptName(name string) (Language, bool) {
+	switch strings.ToLower(name) {
+	case "moonscript":
+		return moon, true
+	case "javascript":
+		return js, true
+	}
+
+	for _, lang := range []Language{lua, wren, moon, js, fennel, squirrel, ruby, python} {
+		if strings.EqualFold(name, lang.scriptName()) {
+			return lang, true
+		}
+	}
+
+	return "", false
+}
+
+func isSupportedLanguage(lang Language) bool {
+	switch lang {
+	case lua, wren, moon, js, fennel, squirrel, ruby, python:
 		return true
 	}
 	return false
 }
+
+// OutputMode is an enum for the kinds of files the compiled code can be written to
+type OutputMode int
+
+const (
+	// outputText writes the compiled code as is to a plain text file
+	outputText OutputMode = iota
+	// outputCart writes the compiled code into the code chunks of a binary .tic cartridge
+	outputCart
+	// outputPNG writes the cartridge with the compiled code into a PNG image of its cover, which the TIC-80 can load
+	outputPNG
+)
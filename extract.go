@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/novemberisms/ticc/assets"
+	"github.com/novemberisms/ticc/cart"
+)
+
+// extractCart implements 'ticc extract game.tic -d outdir', which splits an existing cartridge into a project
+// directory. Building the directory again gives back the same data chunks, but not the same code: the compiler strips
+// the comments (other than the prelude) and the trailing whitespace, and may change the code in other ways.
+//
+//	main.<lang>          the code, including its prelude
+//	tiles.png            the tiles of bank 0, paletted with the palette of the cartridge
+//	sprites.png          the sprites of bank 0, paletted the same way
+//	map.tmj              the map of bank 0, to be edited in Tiled
+//	palette.gpl          the palette of bank 0
+//	cover.png            the cover image, paletted the same way as the sprite sheets
+//	<chunk>.ticchunk     every other chunk, byte for byte
+func extractCart(arguments []string) {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	dirFlag := flags.String("d", "", "The directory to write the project to. Defaults to the name of the cartridge without its extension")
+	langFlag := flags.String("l", string(auto), "The language of the code in the cartridge. By default it is found from the 'script:' tag of the prelude")
+
+	positional := _parseInterspersed(flags, arguments)
+
+	if len(positional) != 1 {
+		checkError(errors.New("usage: ticc extract <cartridge.tic> [-d outdir] [-l language]"))
+	}
+
+	cartFile := positional[0]
+	outDir := *dirFlag
+	if outDir == "" {
+		outDir = strings.TrimSuffix(filepath.Base(cartFile), filepath.Ext(cartFile))
+	}
+
+	tic, err := cart.Load(cartFile)
+	checkError(err)
+
+	code, err := tic.Code()
+	checkError(err)
+
+	language := Language(*langFlag)
+	if language == auto {
+		language = _detectCartLanguage(tic, code)
+	}
+	if !isSupportedLanguage(language) {
+		checkError(fmt.Errorf("invalid language (%s) the supported languages are: lua | moon | wren | js | fnl | nut | rb | py", language))
+	}
+
+	checkError(os.MkdirAll(outDir, 0755))
+
+	// never overwrite an existing project
+	if existing, err := findMainFile(outDir); err == nil {
+		checkError(fmt.Errorf("'%s' already has a main file (%s)", outDir, existing))
+	}
+
+	fmt.Printf("===================TICC=====================\n")
+	fmt.Printf("extracting: %s\n", cartFile)
+	fmt.Printf("language: %s\n", language)
+	fmt.Printf("dir: %s\n", outDir)
+	fmt.Printf("============================================\n")
+
+	_writeExtractedFile(outDir, "main."+string(language), func(filename string) error {
+		return ioutil.WriteFile(filename, []byte(strings.TrimRight(code, "\n")+"\n"), 0644)
+	})
+
+	palette := assets.CartPalette(tic)
+
+	for _, chunk := range tic.Chunks {
+		chunk := chunk
+
+		switch {
+		case chunk.Type == cart.ChunkCode || chunk.Type == cart.ChunkCodeZip:
+			// already written into the main file
+		case chunk.Type == cart.ChunkTiles && chunk.Bank == 0:
+			_writeExtractedFile(outDir, "tiles.png", func(filename string) error {
+				return assets.ExportSpriteSheet(filename, chunk.Data, palette)
+			})
+		case chunk.Type == cart.ChunkSprites && chunk.Bank == 0:
+			_writeExtractedFile(outDir, "sprites.png", func(filename string) error {
+				return assets.ExportSpriteSheet(filename, chunk.Data, palette)
+			})
+		case chunk.Type == cart.ChunkScreen && chunk.Bank == 0:
+			_writeExtractedFile(outDir, "cover.png", func(filename string) error {
+				return assets.ExportCover(filename, chunk.Data, palette)
+			})
+		case chunk.Type == cart.ChunkPalette && chunk.Bank == 0 && len(chunk.Data) <= assets.PaletteSize*3:
+			// larger palette chunks also hold the palette of the border, which has no place in a palette file
+			_writeExtractedFile(outDir, "palette.gpl", func(filename string) error {
+				return assets.ExportGPL(filename, palette, filepath.Base(outDir))
+			})
+		case chunk.Type == cart.ChunkMap && chunk.Bank == 0:
+			_writeExtractedFile(outDir, "map.tmj", func(filename string) error {
+				return assets.ExportTiledMap(filename, chunk.Data, "tiles.png")
+			})
+		default:
+			if _, known := cart.ChunkTypeByName(chunk.Type.String()); !known {
+				fmt.Printf("skipping %s chunk in bank %d\n", chunk.Type, chunk.Bank)
+				continue
+			}
+			_writeExtractedFile(outDir, rawChunkFileName(chunk.Type, chunk.Bank), func(filename string) error {
+				return ioutil.WriteFile(filename, chunk.Data, 0644)
+			})
+		}
+	}
+
+	fmt.Println("OK")
+}
+
+func _writeExtractedFile(outDir string, name string, write func(filename string) error) {
+	filename := path.Join(outDir, name)
+
+	if err := write(filename); err != nil {
+		checkError(fmt.Errorf("Error writing '%s':\n%w", filename, err))
+	}
+
+	fmt.Printf("wrote: %s\n", filename)
+}
+
+// _detectCartLanguage finds the language of the code from the 'script:' tag of its prelude, or from the LANG chunk
+// of newer cartridges. Cartridges with neither are in lua, which is the default of the TIC-80.
+func _detectCartLanguage(tic *cart.Cart, code string) Language {
+	for _, entry := range readPrelude(code) {
+		if entry.key != "script" {
+			continue
+		}
+		if language, ok := languageFromScriptName(entry.value); ok {
+			return language
+		}
+	}
+
+	if chunk := tic.Chunk(cart.ChunkLang, 0); chunk != nil {
+		if language, ok := languageFromScriptName(strings.TrimRight(string(chunk.Data), "\x00")); ok {
+			return language
+		}
+	}
+
+	return lua
+}
+
+// _parseInterspersed parses the flags of a subcommand, allowing them to come after the positional arguments like in
+// 'ticc extract game.tic -d outdir'. The flag package stops at the first positional argument otherwise.
+func _parseInterspersed(flags *flag.FlagSet, arguments []string) []string {
+	positional := []string{}
+
+	for {
+		checkError(flags.Parse(arguments))
+
+		if flags.NArg() == 0 {
+			return positional
+		}
+
+		positional = append(positional, flags.Arg(0))
+		arguments = flags.Args()[1:]
+	}
+}
@@ -0,0 +1,320 @@
+package fennellang
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/literal"
+)
+
+// FennelLanguageService is a container struct that encapsulates a bunch of methods that
+// take in a line of code and do text processing to see if the line matches certain properties
+// based on the language this service provides.
+type FennelLanguageService struct {
+}
+
+// matches `(local <binding> (require <path>))`, where the binding is either a single symbol or a
+// destructuring table like `{: a : b}`, and the path is either a :keyword or a "string"
+var reImportExtract = regexp.MustCompile(`^\s*\((?:local|var|global)\s+(\{[^}]*\}|[\w\-?!]+)\s+\(require\s+:?"?([\w\/\.\-]+)"?\s*\)\s*\)`)
+
+// detects a (require ...) form anywhere in a line
+var reRequire = regexp.MustCompile(`\(require\s`)
+
+// detects an (import-macros ...) form anywhere in a line
+var reImportMacros = regexp.MustCompile(`\(import-macros\s`)
+
+// matches a bare `(require <path>)` without binding the result to anything
+var reRequireFile = regexp.MustCompile(`\(require\s+:?"?([\w\/\.\-]+)"?\s*\)`)
+
+// given a destructuring table like `{: a : b :c renamed}`, extracts the keys being destructured
+var reExtractDestructuredKeys = regexp.MustCompile(`:\s*([\w\-?!]+)`)
+
+// extracts an exported symbol from a top-level definition like (fn update [] ...) or (global player {})
+var reExtractExportedSymbol = regexp.MustCompile(`^\((?:fn|lambda|λ|macro|global|local|var)\s+([\w\-?!]+)`)
+
+// matches the lines that continue the top level statement before them, like indented lines and closing brackets
+var reContinuesDeclaration = regexp.MustCompile(`^(?:\s|[)\]}])`)
+
+// determines if the given line matches the structure needed to be a prelude comment
+var reIsPreludeComment = regexp.MustCompile(`^;+\s*\w+\s*:`)
+
+// detects a macro, which is ;;# followed by its name, so that a line like ;;#------------ stays a comment
+var reIsMacro = regexp.MustCompile(`^\s*;;#\s*\w`)
+
+var reGetMacroType = regexp.MustCompile(`;;#\s*(\w+)`)
+var reGetMacroArgs = regexp.MustCompile(`;;#\s*\w+\s+(.*)$`)
+var reBreakDownMacroArgs = regexp.MustCompile(`\S+`)
+
+// the first \w+ is the ;;# STRING text. The first capturing group gets the string name,
+// and the second capturing group gets the string contents
+var reGetMacroStringDeclarationArgs = regexp.MustCompile(`;;#\s*\w+\s+([\w\-?!]+)\s+(.*)$`)
+
+// fennel symbols may contain dashes and other punctuation, so `max-speed` is a single identifier
+var reIdentifiers = regexp.MustCompile(`[\w\-?!]+`)
+
+// blobDecoder is the function that decodes the strings made by blob.Pack, which is written into the code once
+const blobDecoder = `(global ticc_unblob (fn [s]
+  (let [d [] o []]
+    (var a 0)
+    (var b 0)
+    (var i 1)
+    (for [k 1 (length s)]
+      (set a (+ (* a 64) (- (s:byte k) 48)))
+      (set b (+ b 6))
+      (when (>= b 8)
+        (set b (- b 8))
+        (let [p (^ 2 b) v (math.floor (/ a p))]
+          (table.insert d v)
+          (set a (- a (* v p))))))
+    (while (<= i (length d))
+      (let [c (. d i)]
+        (set i (+ i 1))
+        (if (< c 128)
+            (for [k 0 c]
+              (table.insert o (. d i))
+              (set i (+ i 1)))
+            (do
+              (for [k 1 (- c 125)]
+                (table.insert o (. d i)))
+              (set i (+ i 1))))))
+    o)))`
+
+// literalStyle describes how values are written as fennel sequential and key/value tables
+var literalStyle = literal.Style{
+	Nil:       "nil",
+	True:      "true",
+	False:     "false",
+	ListOpen:  "[",
+	ListClose: "]",
+	MapOpen:   "{",
+	MapClose:  "}",
+	Separator: " ",
+	Key: func(key string) string {
+		if literal.IsIdentifier(key) {
+			return ":" + key + " "
+		}
+		return literal.Escape(key, '"', "") + " "
+	},
+	Quote: func(s string) string {
+		return literal.Escape(s, '"', "")
+	},
+}
+
+// StripUnimportant returns a new line which is the result of stripping all the unimportant or non-usable
+// characters from it. This includes stripping away unneeded whitespace, comments, and any text that comes after comments.
+// The compiler uses StripBlocks instead, which knows about the strings that span several lines.
+func (ls FennelLanguageService) StripUnimportant(line string) string {
+	stripped, _ := ls.StripBlocks(line, nil)
+	return stripped
+}
+
+// IsLineImport determines whether a line of code contains an import statement. In fennel, this is a (require ...)
+// form, or an (import-macros ...) form, which GetImportData refuses.
+func (ls FennelLanguageService) IsLineImport(line string) bool {
+	return reImportMacros.MatchString(line) || reRequire.MatchString(line)
+}
+
+// GetImportData will extract a slice of imported symbols and the relative path to the file that is being imported given
+// a line of code with an import statement.
+//
+// (import-macros ...) can't be compiled. Its macros are only used while fennel compiles the code, so stitching the
+// module in as runtime code would not define them, and the cartridge can't load the module by itself either.
+func (ls FennelLanguageService) GetImportData(line string) (compiler.ImportData, error) {
+	if reImportMacros.MatchString(line) {
+		return compiler.ImportData{}, errors.New(`(import-macros ...) is not supported, since the cartridge can't load the macro module. Define the macros with (macro ...) in a file that is required before they are used instead, since all the files end up in the same chunk`)
+	}
+
+	matchInfo := reImportExtract.FindStringSubmatch(line)
+
+	if len(matchInfo) != 3 {
+		// this means the line does not match the template
+		// (local <binding> (require <path>))
+
+		// check if the line is a bare require without any imports (like '(require :defines)')
+		if requiredFile := reRequireFile.FindStringSubmatch(line); len(requiredFile) > 0 {
+			return compiler.ImportData{
+				Path: requirePathToFile(requiredFile[1]),
+			}, nil
+		}
+
+		return compiler.ImportData{}, errors.New(`import line does not match the templates: '(local {binding} (require :{importpath}))' or '(require :{importpath})'`)
+	}
+
+	return compiler.ImportData{
+		Symbols: bindingToSymbols(matchInfo[1]),
+		Path:    requirePathToFile(matchInfo[2]),
+	}, nil
+}
+
+// bindingToSymbols finds the symbols that are expected to be exported by the imported file given the binding
+// form of an import. A destructuring table imports each of its keys, while a plain symbol imports itself.
+func bindingToSymbols(binding string) []string {
+	if strings.HasPrefix(binding, "{") {
+		symbols := []string{}
+		for _, key := range reExtractDestructuredKeys.FindAllStringSubmatch(binding, -1) {
+			symbols = append(symbols, key[1])
+		}
+		return symbols
+	}
+	return []string{binding}
+}
+
+// requirePathToFile converts a fennel module path like "entities.player" into the relative path of its file
+func requirePathToFile(modulePath string) string {
+	modulePath = strings.TrimSuffix(modulePath, ".fnl")
+	return strings.ReplaceAll(modulePath, ".", "/") + ".fnl"
+}
+
+// IsExportDeclaration determines if a line contains a top-level definition that should be available to other
+// files importing this one
+func (ls FennelLanguageService) IsExportDeclaration(line string) bool {
+	// in fennel, the following are export declarations
+	// * (fn update [] ...)
+	// * (global player {})
+	// * (local SPEED 2)
+	// * (macro when-let [...] ...)
+	// and they must all have zero leading indentation. Because every file is stitched into the same chunk,
+	// a top-level local is still visible to the files that come after it.
+	return reExtractExportedSymbol.MatchString(line)
+}
+
+// GetExportDeclarations extracts a list of exported symbols from the line
+func (ls FennelLanguageService) GetExportDeclarations(line string) []string {
+	matchInfo := reExtractExportedSymbol.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return matchInfo[1:2]
+	}
+
+	return []string{}
+}
+
+// ContinuesDeclaration determines if a line continues the top level declaration on the lines before it, so that the
+// declaration can be left out as a whole when it is not used
+func (ls FennelLanguageService) ContinuesDeclaration(statement []string, line string) bool {
+	return reContinuesDeclaration.MatchString(line)
+}
+
+// ExtractPrelude extracts a string from the supplied main file code. This string is the prelude-
+// a set of comments that must appear at the top of a file used by the TIC-80 to determine the title,
+// author, description, language, and input type of the game.
+//
+// Normally, comments are stripped out by
+// StripUnimportant, which is why this needs to be its own separate method
+func (ls FennelLanguageService) ExtractPrelude(mainFileCode string) string {
+	result := ""
+	// split the code into lines
+	lines := strings.Split(mainFileCode, "\n")
+	for _, line := range lines {
+		if reIsPreludeComment.MatchString(line) {
+			result = result + line + "\n"
+		} else {
+			break
+		}
+	}
+	return result
+}
+
+// FormatLiteral writes a value built by the literal package as a fennel table, string, or other literal
+func (ls FennelLanguageService) FormatLiteral(value interface{}) string {
+	return literal.Format(value, literalStyle)
+}
+
+// FormatDeclaration writes a global definition that makes the value available to the whole program
+func (ls FennelLanguageService) FormatDeclaration(name string, value string) string {
+	return "(global " + name + " " + value + ")"
+}
+
+// BlobDecoder writes the function that turns the strings made by blob.Pack back into bytes, as a global fennel
+// function. The bytes come back as a table indexed from 1.
+func (ls FennelLanguageService) BlobDecoder() []string {
+	return strings.Split(blobDecoder, "\n")
+}
+
+// FormatBlobDecode writes a call to the function from BlobDecoder
+func (ls FennelLanguageService) FormatBlobDecode(packed string) string {
+	return "(ticc_unblob " + ls.FormatLiteral(packed) + ")"
+}
+
+// SubstituteDefines takes in a line of code and the current set of previously-declared defines. It then
+// detects any occurences of the defines that should be replaced and returns a string with these occurences
+// replaced by their correct definitions.
+func (ls FennelLanguageService) SubstituteDefines(line string, defines map[string]string) string {
+	return reIdentifiers.ReplaceAllStringFunc(line, func(identifier string) string {
+		replacement, isDefined := defines[identifier]
+		if !isDefined {
+			return identifier
+		}
+		return replacement
+	})
+}
+
+// IsLineMacro determines if the given line constitutes a macro declaration of some sort
+func (ls FennelLanguageService) IsLineMacro(line string) bool {
+	return reIsMacro.MatchString(line)
+}
+
+// GetMacroType will determine what type of macro a given line is, provided that it has been
+// detected previously by IsLineMacro.
+func (ls FennelLanguageService) GetMacroType(line string) compiler.MacroType {
+	matchInfo := reGetMacroType.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return compiler.MacroTypeUnknown
+	}
+
+	symbol := matchInfo[1]
+
+	switch strings.ToUpper(symbol) {
+	case "DEFINE":
+		return compiler.MacroTypeDefine
+	case "STRING":
+		return compiler.MacroTypeString
+	case "DATA":
+		return compiler.MacroTypeData
+	case "EMBED":
+		return compiler.MacroTypeEmbed
+	case "BLOB":
+		return compiler.MacroTypeBlob
+	case "IF":
+		return compiler.MacroTypeIf
+	case "ELSEIF":
+		return compiler.MacroTypeElseIf
+	case "ELSE":
+		return compiler.MacroTypeElse
+	case "ENDIF":
+		return compiler.MacroTypeEndIf
+	default:
+		return compiler.MacroTypeUnknown
+	}
+}
+
+// GetMacroArgs will return a slice of all the space-separated values that follow a
+// macro definition
+func (ls FennelLanguageService) GetMacroArgs(line string) []string {
+	matchInfo := reGetMacroArgs.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return []string{}
+	}
+
+	fullArgs := matchInfo[1]
+
+	return reBreakDownMacroArgs.FindAllString(fullArgs, -1)
+}
+
+// GetMacroStringDeclaration extracts the name and the contents of a string macro
+func (ls FennelLanguageService) GetMacroStringDeclaration(line string) (string, string, error) {
+	matchInfo := reGetMacroStringDeclarationArgs.FindStringSubmatch(line)
+
+	// the first string in matchInfo is always the full matched text
+	// the second string is the string name
+	// the third string is the string contents
+	if len(matchInfo) != 3 {
+		return "", "", errors.New("invalid format for string macro. must be ;;#string [STRING_NAME] STRING CONTENTS")
+	}
+
+	return matchInfo[1], matchInfo[2], nil
+}
@@ -0,0 +1,25 @@
+package fennellang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImportMacros(t *testing.T) {
+	ls := FennelLanguageService{}
+	line := "(import-macros {: when-let} :macros)"
+
+	if !ls.IsLineImport(line) {
+		t.Fatal("expected (import-macros ...) to be treated as an import")
+	}
+
+	_, err := ls.GetImportData(line)
+	if err == nil || !strings.Contains(err.Error(), "(macro ...)") {
+		t.Errorf("expected an error that points to (macro ...), got %v", err)
+	}
+
+	data, err := ls.GetImportData("(local {: update} (require :entities.player))")
+	if err != nil || data.Path != "entities/player.fnl" || len(data.Symbols) != 1 || data.Symbols[0] != "update" {
+		t.Errorf("expected a require to import update from entities/player.fnl, got %+v (%v)", data, err)
+	}
+}
@@ -0,0 +1,54 @@
+package fennellang
+
+import (
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+// StripBlocks strips the comments and the trailing whitespace from a line like StripUnimportant, keeping track of the
+// strings that span several lines. Strings are skipped, so a ; inside of one is not mistaken for a comment.
+func (ls FennelLanguageService) StripBlocks(line string, open *compiler.OpenBlock) (string, *compiler.OpenBlock) {
+	i := 0
+
+	if open != nil {
+		end := _skipStringBody(line, 0)
+		if end < 0 {
+			// the string is kept exactly as it is, including the whitespace at the end of the line
+			return line, open
+		}
+		i = end
+	}
+
+	for i < len(line) {
+		switch line[i] {
+		case ';':
+			return strings.TrimRight(line[:i], " \t\n\r"), nil
+		case '"':
+			end := _skipStringBody(line, i+1)
+			if end < 0 {
+				return line, &compiler.OpenBlock{Closing: `"`}
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+
+	return strings.TrimRight(line, " \t\n\r"), nil
+}
+
+// _skipStringBody finds the position right after the quote that closes a string whose body starts at the given
+// position, or -1 if the string is not closed on this line. A backslash escapes the character after it.
+func _skipStringBody(line string, start int) int {
+	for i := start; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,51 @@
+package fennellang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func TestStripBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{
+			name:     "line comments",
+			lines:    []string{"(var x 1) ; one", ";; only a comment", "  (set x 2)  "},
+			expected: []string{"(var x 1)", "", "  (set x 2)"},
+		},
+		{
+			name:     "semicolons inside strings",
+			lines:    []string{`(print "score; 10" 0 0)) ; comment`, `(print "a \" ; b" :c)`},
+			expected: []string{`(print "score; 10" 0 0))`, `(print "a \" ; b" :c)`},
+		},
+		{
+			name:     "strings over several lines",
+			lines:    []string{`(local help "Press Z  `, `; not a comment \"  `, "", `to jump") ; comment`},
+			expected: []string{`(local help "Press Z  `, `; not a comment \"  `, "", `to jump")`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ls := FennelLanguageService{}
+			var open *compiler.OpenBlock
+
+			for i, line := range test.lines {
+				var stripped string
+				stripped, open = ls.StripBlocks(line, open)
+
+				if stripped != test.expected[i] {
+					t.Errorf("line %d: expected %q, got %q", i+1, test.expected[i], stripped)
+				}
+			}
+
+			if open != nil {
+				t.Errorf("expected everything to be closed, but %q is still open", open.Closing)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/novemberisms/ticc/cart"
+)
+
+// codeSizeLimit is the most code the TIC-80 will run. Only TIC-80 PRO can spread larger programs across the code banks
+const codeSizeLimit = cart.CodeBankSize
+
+// requiredPreludeKeys are the keys that every cartridge should have in its prelude
+var requiredPreludeKeys = []string{"title", "author", "script"}
+
+// inspectCart implements 'ticc inspect cart.tic', which lists the chunks of a cartridge and checks its code and
+// prelude for problems. The program exits with a non-zero status if any problems were found.
+func inspectCart(arguments []string) {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	langFlag := flags.String("l", string(auto), "The language the cartridge is expected to be in. By default it is the language of the main file in the directory given by -d, if there is one")
+	dirFlag := flags.String("d", ".", "The project directory to detect the expected language from")
+
+	positional := _parseInterspersed(flags, arguments)
+
+	if len(positional) != 1 {
+		checkError(errors.New("usage: ticc inspect <cartridge.tic> [-l language] [-d dir]"))
+	}
+
+	cartFile := positional[0]
+
+	Args.language = Language(*langFlag)
+	if Args.language == auto {
+		// an expected language is only known when inspecting the output of a project
+		Args.language = ""
+		if mainFile, err := findMainFile(*dirFlag); err == nil {
+			Args.language = Language(strings.TrimPrefix(filepath.Ext(mainFile), "."))
+		}
+	} else if !isSupportedLanguage(Args.language) {
+		checkError(fmt.Errorf("invalid language (%s) the supported languages are: lua | moon | wren | js | fnl | nut | rb | py", Args.language))
+	}
+
+	tic, err := cart.Load(cartFile)
+	checkError(err)
+
+	fmt.Printf("===================TICC=====================\n")
+	fmt.Printf("inspecting: %s\n", cartFile)
+	fmt.Printf("============================================\n")
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "TYPE\tBANK\tSIZE\tCRC32\t\n")
+	for _, chunk := range tic.Chunks {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%08x\t\n", chunk.Type, chunk.Bank, len(chunk.Data), crc32.ChecksumIEEE(chunk.Data))
+	}
+	table.Flush()
+
+	problems := []string{}
+
+	code, err := tic.Code()
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	fmt.Printf("--------------------------------------------\n")
+	fmt.Printf("code: %d / %d bytes (%.1f%%)\n", len(code), codeSizeLimit, float64(len(code))*100/codeSizeLimit)
+
+	if len(code) > codeSizeLimit {
+		problems = append(problems, fmt.Sprintf("the code is %d bytes over the limit, and will only run in TIC-80 PRO", len(code)-codeSizeLimit))
+	}
+
+	prelude := readPrelude(code)
+
+	fmt.Printf("prelude:\n")
+	for _, entry := range prelude {
+		fmt.Printf("  %s: %s\n", entry.key, entry.value)
+	}
+
+	problems = append(problems, _checkPrelude(prelude)...)
+
+	fmt.Printf("--------------------------------------------\n")
+
+	if len(problems) == 0 {
+		fmt.Println("OK")
+		return
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("problem: %s\n", problem)
+	}
+	fmt.Printf("%d problem(s) found\n", len(problems))
+	os.Exit(1)
+}
+
+// _checkPrelude makes sure the required keys are in the prelude, and that its script tag names a language the
+// TIC-80 knows and which matches Args.language (if it is known)
+func _checkPrelude(prelude []preludeEntry) []string {
+	problems := []string{}
+
+	values := make(map[string]string)
+	for _, entry := range prelude {
+		if _, duplicate := values[entry.key]; duplicate {
+			problems = append(problems, fmt.Sprintf("the '%s' key appears more than once in the prelude", entry.key))
+		}
+		values[entry.key] = entry.value
+	}
+
+	for _, key := range requiredPreludeKeys {
+		if values[key] == "" {
+			problems = append(problems, fmt.Sprintf("the '%s' key is missing from the prelude", key))
+		}
+	}
+
+	script, hasScript := values["script"]
+	if !hasScript || script == "" {
+		// the TIC-80 runs cartridges without a script tag as lua
+		script = lua.scriptName()
+	}
+
+	language, known := languageFromScriptName(script)
+
+	switch {
+	case !known:
+		problems = append(problems, fmt.Sprintf("'script: %s' is not a language the TIC-80 supports", script))
+	case Args.language != "" && language != Args.language:
+		problems = append(problems, fmt.Sprintf("the cartridge is run as '%s', but the language is %s (expected 'script: %s')", script, Args.language, Args.language.scriptName()))
+	}
+
+	return problems
+}
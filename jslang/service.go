@@ -0,0 +1,379 @@
+package jslang
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/literal"
+)
+
+// JavascriptLanguageService is a container struct that encapsulates a bunch of methods that
+// take in a line of code and do text processing to see if the line matches certain properties
+// based on the language this service provides.
+type JavascriptLanguageService struct {
+}
+
+// matches `import { <symbols> } from "<path>"`
+var reImportExtract = regexp.MustCompile(`^\s*import\s*\{([^}]*)\}\s*from\s*["']([\w\/\.]+)["']`)
+
+// matches a default import `import <symbol> from "<path>"`
+var reDefaultImport = regexp.MustCompile(`^\s*import\s+(\w+)\s+from\s*["']([\w\/\.]+)["']`)
+
+// matches a bare import statement `import "<path>"`
+var reBareImport = regexp.MustCompile(`^\s*import\s*["']([\w\/\.]+)["']`)
+
+// given a comma-separated string of values, extracts each word. Renamed imports (`A as B`) are not supported
+// since the files are stitched into the same scope, so only the first word of each import is relevant
+var reExtractImportSymbols = regexp.MustCompile(`\b\w+\b`)
+
+// matches the `export` keyword at the start of a top-level declaration
+var reExportKeyword = regexp.MustCompile(`^export\s+(?:default\s+)?`)
+
+// extracts an exported symbol of kind export function [identifier] or export class [identifier]
+var reExportFunctionOrClass = regexp.MustCompile(`^export\s+(?:default\s+)?(?:function\s*\*?|class)\s+(\w+)`)
+
+// extracts the exported symbols of kind export const|let|var [identifier] = ..., [identifier] = ...
+var reExportVariable = regexp.MustCompile(`^export\s+(?:const|let|var)\s+(.+)`)
+
+// matches the start of an export list, which is followed by the names it exports
+var reExportListStart = regexp.MustCompile(`^export\s*\{`)
+
+// extracts the symbols of an export list of kind export { [identifier], [identifier] }
+var reExportList = regexp.MustCompile(`^export\s*\{([^}]*)\}\s*;?\s*$`)
+
+// finds a renamed name in an export list, like the 'as' in export { A as B }
+var reRenamedExport = regexp.MustCompile(`\bas\b`)
+
+// given the declarations following an export const|let|var, finds each declared name
+var reExtractDeclaredNames = regexp.MustCompile(`(?:^|,)\s*(\w+)`)
+
+// matches the lines that continue the top level statement before them, like indented lines and '}'
+var reContinuesDeclaration = regexp.MustCompile(`^(?:\s|[)\]}])`)
+
+var reIsPreludeComment = regexp.MustCompile(`^\/\/\s*\w+\s*:`)
+
+// detects a macro, which is //# followed by its name, so that a line like //#------------ stays a comment
+var reIsMacro = regexp.MustCompile(`^\s*\/\/#\s*\w`)
+
+var reGetMacroType = regexp.MustCompile(`\/\/#\s*(\w+)`)
+var reGetMacroArgs = regexp.MustCompile(`\/\/#\s*\w+\s+(.*)$`)
+var reBreakDownMacroArgs = regexp.MustCompile(`\S+`)
+
+var reGetMacroStringDeclarationArgs = regexp.MustCompile(`\/\/#\s*\w+\s+(\w+)\s+(.*)$`)
+
+var reIdentifiers = regexp.MustCompile(`\w+`)
+
+// blobDecoder is the function that decodes the strings made by blob.Pack, which is written into the code once
+const blobDecoder = `function ticc_unblob(s) {
+  var d = [], o = [], a = 0, b = 0, i = 0, k, c;
+  for (k = 0; k < s.length; k++) {
+    a = (a << 6) | (s.charCodeAt(k) - 48);
+    b += 6;
+    if (b >= 8) {
+      b -= 8;
+      d.push(a >> b);
+      a &= (1 << b) - 1;
+    }
+  }
+  while (i < d.length) {
+    c = d[i++];
+    if (c < 128) {
+      for (k = 0; k <= c; k++) o.push(d[i++]);
+    } else {
+      for (k = 0; k < c - 125; k++) o.push(d[i]);
+      i++;
+    }
+  }
+  return o;
+}`
+
+// literalStyle describes how values are written as javascript arrays and objects
+var literalStyle = literal.Style{
+	Nil:       "null",
+	True:      "true",
+	False:     "false",
+	ListOpen:  "[",
+	ListClose: "]",
+	MapOpen:   "{",
+	MapClose:  "}",
+	Separator: ",",
+	Key: func(key string) string {
+		return literal.Escape(key, '"', "") + ":"
+	},
+	Quote: func(s string) string {
+		return literal.Escape(s, '"', "")
+	},
+}
+
+// StripUnimportant returns a new line which is the result of stripping all the unimportant or non-usable
+// characters from it. This includes stripping away unneeded whitespace, comments, and any text that comes after comments
+// The compiler uses StripBlocks instead, which knows about the comments and strings that span several lines.
+func (ls JavascriptLanguageService) StripUnimportant(line string) string {
+	stripped, _ := ls.StripBlocks(line, nil)
+	return stripped
+}
+
+// IsLineImport determines whether a line of code contains an import statement. In javascript, this is a line
+// starting with the 'import' token.
+func (ls JavascriptLanguageService) IsLineImport(line string) bool {
+	matched, _ := regexp.MatchString(`^\s*import\b`, line)
+	return matched
+}
+
+// GetImportData will extract a slice of imported symbols and the relative path to the file that is being imported given
+// a line of code with an import statement
+func (ls JavascriptLanguageService) GetImportData(line string) (compiler.ImportData, error) {
+	if matchInfo := reImportExtract.FindStringSubmatch(line); len(matchInfo) == 3 {
+		symbols := []string{}
+		for _, symbol := range strings.Split(matchInfo[1], ",") {
+			if word := reExtractImportSymbols.FindString(symbol); word != "" {
+				symbols = append(symbols, word)
+			}
+		}
+
+		return compiler.ImportData{
+			Symbols: symbols,
+			Path:    importPathToFile(matchInfo[2]),
+		}, nil
+	}
+
+	if matchInfo := reDefaultImport.FindStringSubmatch(line); len(matchInfo) == 3 {
+		return compiler.ImportData{
+			Symbols: matchInfo[1:2],
+			Path:    importPathToFile(matchInfo[2]),
+		}, nil
+	}
+
+	if matchInfo := reBareImport.FindStringSubmatch(line); len(matchInfo) == 2 {
+		return compiler.ImportData{
+			Path: importPathToFile(matchInfo[1]),
+		}, nil
+	}
+
+	return compiler.ImportData{}, errors.New(`import line does not match the templates: 'import { {symbols} } from "{path}"', 'import {symbol} from "{path}"' or 'import "{path}"'`)
+}
+
+// importPathToFile converts an ES module specifier like "./entities/player" into the relative path of its file
+func importPathToFile(specifier string) string {
+	specifier = strings.TrimPrefix(specifier, "./")
+	return strings.TrimSuffix(specifier, ".js") + ".js"
+}
+
+// IsExportDeclaration determines if a line contains a declaration that should be available to other files
+// importing this one
+func (ls JavascriptLanguageService) IsExportDeclaration(line string) bool {
+	// in javascript, the following are export declarations
+	// * export function update() {
+	// * export class Entity {
+	// * export const SPEED = 2, GRAVITY = 1
+	// * export { update, Entity }
+	// and they must all have zero leading indentation
+	return reExportKeyword.MatchString(line)
+}
+
+// GetExportDeclarations extracts a list of exported symbols from the line
+func (ls JavascriptLanguageService) GetExportDeclarations(line string) []string {
+	matchInfo := reExportFunctionOrClass.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return matchInfo[1:2]
+	}
+
+	matchInfo = reExportVariable.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		symbols := []string{}
+		for _, declared := range reExtractDeclaredNames.FindAllStringSubmatch(_stripBracketed(matchInfo[1]), -1) {
+			symbols = append(symbols, declared[1])
+		}
+		return symbols
+	}
+
+	matchInfo = reExportList.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return reExtractImportSymbols.FindAllString(matchInfo[1], -1)
+	}
+
+	return []string{}
+}
+
+// ContinuesDeclaration determines if a line continues the top level declaration on the lines before it, so that the
+// declaration can be left out as a whole when it is not used
+func (ls JavascriptLanguageService) ContinuesDeclaration(statement []string, line string) bool {
+	return reContinuesDeclaration.MatchString(line)
+}
+
+// StripExportKeyword removes the 'export' keyword from an export declaration, since every file ends up stitched
+// into the same script and the keyword would otherwise be a syntax error in the TIC-80. An export list only names
+// declarations made elsewhere in the file, so nothing is left of it.
+func (ls JavascriptLanguageService) StripExportKeyword(line string) (string, error) {
+	if !reExportListStart.MatchString(line) {
+		return reExportKeyword.ReplaceAllString(line, ""), nil
+	}
+
+	matchInfo := reExportList.FindStringSubmatch(line)
+
+	if len(matchInfo) == 0 {
+		return "", errors.New("export lists must fit on a single line, and re-exporting from another file is not supported")
+	}
+
+	if reRenamedExport.MatchString(matchInfo[1]) {
+		return "", errors.New("renamed exports (`A as B`) are not supported, since all the files are stitched into the same scope")
+	}
+
+	return "", nil
+}
+
+// _stripBracketed removes anything nested inside brackets, braces, parentheses or quotes, so that commas inside
+// initializers like `export const A = [1, 2], B = f(x, y)` do not get mistaken for separate declarations
+func _stripBracketed(declarations string) string {
+	var result strings.Builder
+	depth := 0
+	var quote rune
+
+	for _, r := range declarations {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			continue
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+			continue
+		case r == '(' || r == '[' || r == '{':
+			depth++
+			continue
+		case r == ')' || r == ']' || r == '}':
+			depth--
+			continue
+		}
+
+		if depth == 0 {
+			result.WriteRune(r)
+		}
+	}
+
+	return result.String()
+}
+
+// ExtractPrelude extracts a string from the supplied main file code. This string is the prelude-
+// a set of comments that must appear at the top of a file used by the TIC-80 to determine the title,
+// author, description, language, and input type of the game.
+func (ls JavascriptLanguageService) ExtractPrelude(mainFileCode string) string {
+	result := ""
+
+	lines := strings.Split(mainFileCode, "\n")
+	for _, line := range lines {
+		if reIsPreludeComment.MatchString(line) {
+			result = result + line + "\n"
+		} else {
+			break
+		}
+	}
+
+	return result
+}
+
+// FormatLiteral writes a value built by the literal package as a javascript array, object, string, or other literal
+func (ls JavascriptLanguageService) FormatLiteral(value interface{}) string {
+	return literal.Format(value, literalStyle)
+}
+
+// FormatDeclaration writes a top-level variable declaration that makes the value available to the whole program
+func (ls JavascriptLanguageService) FormatDeclaration(name string, value string) string {
+	return "var " + name + " = " + value + ";"
+}
+
+// BlobDecoder writes the function that turns the strings made by blob.Pack back into bytes, as a javascript function.
+func (ls JavascriptLanguageService) BlobDecoder() []string {
+	return strings.Split(blobDecoder, "\n")
+}
+
+// FormatBlobDecode writes a call to the function from BlobDecoder
+func (ls JavascriptLanguageService) FormatBlobDecode(packed string) string {
+	return "ticc_unblob(" + ls.FormatLiteral(packed) + ")"
+}
+
+// SubstituteDefines takes in a line of code and the current set of previously-declared defines and replaces
+// any occurences of the defines with their definitions.
+func (ls JavascriptLanguageService) SubstituteDefines(line string, defines map[string]string) string {
+	return reIdentifiers.ReplaceAllStringFunc(line, func(identifier string) string {
+		replacement, isDefined := defines[identifier]
+		if !isDefined {
+			return identifier
+		}
+		return replacement
+	})
+}
+
+// IsLineMacro determines if the given line constitutes a macro declaration of some sort
+func (ls JavascriptLanguageService) IsLineMacro(line string) bool {
+	return reIsMacro.MatchString(line)
+}
+
+// GetMacroType will determine what type of macro a given line is, provided that it has been
+// detected previously by IsLineMacro.
+func (ls JavascriptLanguageService) GetMacroType(line string) compiler.MacroType {
+	matchInfo := reGetMacroType.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return compiler.MacroTypeUnknown
+	}
+
+	symbol := matchInfo[1]
+
+	switch strings.ToUpper(symbol) {
+	case "DEFINE":
+		return compiler.MacroTypeDefine
+	case "STRING":
+		return compiler.MacroTypeString
+	case "DATA":
+		return compiler.MacroTypeData
+	case "EMBED":
+		return compiler.MacroTypeEmbed
+	case "BLOB":
+		return compiler.MacroTypeBlob
+	case "IF":
+		return compiler.MacroTypeIf
+	case "ELSEIF":
+		return compiler.MacroTypeElseIf
+	case "ELSE":
+		return compiler.MacroTypeElse
+	case "ENDIF":
+		return compiler.MacroTypeEndIf
+	default:
+		return compiler.MacroTypeUnknown
+	}
+}
+
+// GetMacroArgs will return a slice of all the space-separated values that follow a
+// macro definition
+func (ls JavascriptLanguageService) GetMacroArgs(line string) []string {
+	matchInfo := reGetMacroArgs.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return []string{}
+	}
+
+	fullArgs := matchInfo[1]
+
+	return reBreakDownMacroArgs.FindAllString(fullArgs, -1)
+}
+
+// GetMacroStringDeclaration extracts the name and the contents of a string macro
+func (ls JavascriptLanguageService) GetMacroStringDeclaration(line string) (string, string, error) {
+	matchInfo := reGetMacroStringDeclarationArgs.FindStringSubmatch(line)
+
+	// the first string in matchInfo is always the full matched text
+	// the second string is the string name
+	// the third string is the string contents
+	if len(matchInfo) != 3 {
+		return "", "", errors.New("invalid format for string macro. must be //#string [STRING_NAME] STRING CONTENTS")
+	}
+
+	return matchInfo[1], matchInfo[2], nil
+}
@@ -0,0 +1,33 @@
+package jslang
+
+import (
+	"testing"
+)
+
+func TestExportList(t *testing.T) {
+	ls := JavascriptLanguageService{}
+	line := "export { update, Entity };"
+
+	if !ls.IsExportDeclaration(line) {
+		t.Fatal("expected an export list to be an export declaration")
+	}
+
+	symbols := ls.GetExportDeclarations(line)
+	if len(symbols) != 2 || symbols[0] != "update" || symbols[1] != "Entity" {
+		t.Errorf("expected [update Entity], got %q", symbols)
+	}
+
+	if stripped, err := ls.StripExportKeyword(line); err != nil || stripped != "" {
+		t.Errorf("expected nothing to be left of the list, got %q (%v)", stripped, err)
+	}
+
+	for _, invalid := range []string{"export {", "export { A as B }", `export { A } from "./a"`} {
+		if _, err := ls.StripExportKeyword(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+
+	if stripped, err := ls.StripExportKeyword("export const A = 1, B = 2"); err != nil || stripped != "const A = 1, B = 2" {
+		t.Errorf("expected the keyword to be removed, got %q (%v)", stripped, err)
+	}
+}
@@ -0,0 +1,61 @@
+package jslang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func TestStripBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{
+			name:     "line comments",
+			lines:    []string{"let x = 1; // one", "// only a comment", "  y = 2;  "},
+			expected: []string{"let x = 1;", "", "  y = 2;"},
+		},
+		{
+			name:     "slashes inside strings",
+			lines:    []string{`const url = "http://tic80.com"; // site`, `let s = 'a // b', t = "\" // "`},
+			expected: []string{`const url = "http://tic80.com";`, `let s = 'a // b', t = "\" // "`},
+		},
+		{
+			name:     "block comments",
+			lines:    []string{"let x = 1; /* starts", "code = 2;", "ends */ let y = 3;", "/**", " * docs", " */"},
+			expected: []string{"let x = 1;", "", "let y = 3;", "", "", ""},
+		},
+		{
+			name:     "block comment in the middle of a line",
+			lines:    []string{"a/* note */+ b", "  /* note */ c = 1;"},
+			expected: []string{"a + b", "  c = 1;"},
+		},
+		{
+			name:     "template literals",
+			lines:    []string{"const s = `first  ", "// not a comment  ", "", "${x}` + t; // comment"},
+			expected: []string{"const s = `first  ", "// not a comment  ", "", "${x}` + t;"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ls := JavascriptLanguageService{}
+			var open *compiler.OpenBlock
+
+			for i, line := range test.lines {
+				var stripped string
+				stripped, open = ls.StripBlocks(line, open)
+
+				if stripped != test.expected[i] {
+					t.Errorf("line %d: expected %q, got %q", i+1, test.expected[i], stripped)
+				}
+			}
+
+			if open != nil {
+				t.Errorf("expected everything to be closed, but %q is still open", open.Closing)
+			}
+		})
+	}
+}
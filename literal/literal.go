@@ -0,0 +1,124 @@
+package literal
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A Map is a map literal that remembers the order its keys were added in, so the generated code is the same
+// every time it is compiled
+type Map struct {
+	Keys   []string
+	Values []interface{}
+}
+
+// Set adds a key to the map, or replaces its value if the key is already there
+func (m *Map) Set(key string, value interface{}) {
+	for i, existing := range m.Keys {
+		if existing == key {
+			m.Values[i] = value
+			return
+		}
+	}
+	m.Keys = append(m.Keys, key)
+	m.Values = append(m.Values, value)
+}
+
+// A Style describes how the literals of one of the languages are written
+type Style struct {
+	Nil   string
+	True  string
+	False string
+
+	ListOpen  string
+	ListClose string
+	MapOpen   string
+	MapClose  string
+	// Separator goes in between the items of a list or map
+	Separator string
+
+	// Key writes the key of a map entry, along with whatever goes in between the key and the value
+	Key func(key string) string
+	// Quote writes a string literal
+	Quote func(s string) string
+}
+
+var reIdentifier = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+// IsIdentifier determines if the key can be written as a bare identifier in most languages
+func IsIdentifier(key string) bool {
+	return reIdentifier.MatchString(key)
+}
+
+// Format writes the value as a literal in the given style. The value may be nil, a bool, any kind of number,
+// a string, a []interface{} or a *Map, with lists and maps holding any of those in turn.
+func Format(value interface{}, style Style) string {
+	switch v := value.(type) {
+	case nil:
+		return style.Nil
+	case bool:
+		if v {
+			return style.True
+		}
+		return style.False
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case json.Number:
+		return v.String()
+	case string:
+		return style.Quote(v)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = Format(item, style)
+		}
+		return style.ListOpen + strings.Join(items, style.Separator) + style.ListClose
+	case *Map:
+		items := make([]string, len(v.Keys))
+		for i, key := range v.Keys {
+			items[i] = style.Key(key) + Format(v.Values[i], style)
+		}
+		return style.MapOpen + strings.Join(items, style.Separator) + style.MapClose
+	default:
+		return style.Quote(fmt.Sprint(v))
+	}
+}
+
+// Escape writes the string as a literal between the given quotes, escaping the backslash, the quote itself, any
+// control characters, and any extra characters that have a special meaning inside strings of the language
+// (like the '#' of ruby's interpolation)
+func Escape(s string, quote byte, extra string) string {
+	var result strings.Builder
+
+	result.WriteByte(quote)
+
+	for i := 0; i < len(s); i++ {
+		char := s[i]
+		switch {
+		case char == '\\' || char == quote || strings.IndexByte(extra, char) >= 0:
+			result.WriteByte('\\')
+			result.WriteByte(char)
+		case char == '\n':
+			result.WriteString(`\n`)
+		case char == '\r':
+			result.WriteString(`\r`)
+		case char == '\t':
+			result.WriteString(`\t`)
+		case char < 0x20 || char == 0x7F:
+			fmt.Fprintf(&result, `\x%02x`, char)
+		default:
+			result.WriteByte(char)
+		}
+	}
+
+	result.WriteByte(quote)
+
+	return result.String()
+}
@@ -0,0 +1,150 @@
+package literal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matches the numbers that a CSV cell is read as, leaving out things like 'inf' and 'nan' that strconv also accepts
+var reNumber = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// ParseFile reads a .csv or .json file into a value that can be written with Format
+func ParseFile(filename string) (interface{}, error) {
+	data, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return ParseCSV(data)
+	case ".json":
+		return ParseJSON(data)
+	default:
+		return nil, fmt.Errorf("unknown data format '%s'. Use .csv or .json instead", filepath.Ext(filename))
+	}
+}
+
+// ParseCSV reads a CSV table whose first row names the columns. Every other row becomes a Map from the names of the
+// columns to its cells, which are read as numbers or booleans where they look like them, as nil if they are empty,
+// and as strings otherwise.
+func ParseCSV(data []byte) ([]interface{}, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("the table has no header row")
+	}
+
+	header := records[0]
+	rows := make([]interface{}, 0, len(records)-1)
+
+	for _, record := range records[1:] {
+		row := &Map{}
+		for i, column := range header {
+			row.Set(column, inferCell(record[i]))
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func inferCell(cell string) interface{} {
+	cell = strings.TrimSpace(cell)
+
+	switch {
+	case cell == "":
+		return nil
+	case strings.EqualFold(cell, "true"):
+		return true
+	case strings.EqualFold(cell, "false"):
+		return false
+	case reNumber.MatchString(cell):
+		if value, err := strconv.ParseInt(cell, 10, 64); err == nil {
+			return value
+		}
+		if value, err := strconv.ParseFloat(cell, 64); err == nil {
+			return value
+		}
+	}
+
+	return cell
+}
+
+// ParseJSON reads a JSON value. Objects become Maps which keep their keys in the same order as the file, so the
+// generated code does not change from one build to the next.
+func ParseJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	value, err := parseJSONValue(decoder)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the end of the JSON value")
+	}
+
+	return value, nil
+}
+
+func parseJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			item, err := parseJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		// the closing bracket
+		_, err := decoder.Token()
+		return list, err
+	case json.Delim('{'):
+		object := &Map{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := parseJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object.Set(key.(string), value)
+		}
+		// the closing brace
+		_, err := decoder.Token()
+		return object, err
+	}
+
+	// strings, numbers, booleans and nulls are already the values that Format expects
+	return token, nil
+}
@@ -0,0 +1,325 @@
+package lualang
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/literal"
+)
+
+// LuaLanguageService is a container struct that encapsulates a bunch of methods that
+// take in a line of code and do text processing to see if the line matches certain properties
+// based on the language this service provides.
+type LuaLanguageService struct {
+}
+
+// matches `local <symbol> = require "<path>"` as well as the parenthesized and single-quoted variants
+var reImportExtract = regexp.MustCompile(`^\s*local\s+(\w+)\s*=\s*require\s*\(?\s*["']([\w\/\.]+)["']\s*\)?`)
+
+// matches a bare `require "<path>"` without assigning the result to anything
+var reRequireFile = regexp.MustCompile(`require\s*\(?\s*["']([\w\/\.]+)["']\s*\)?`)
+
+// extracts the exported symbols of kind [identifier], [identifier] = ...
+var reExtractExportedSymbols = regexp.MustCompile(`^(\w+(?:\s*,\s*\w+)*)\s*=[^=]`)
+
+// extracts an exported symbol of kind function [identifier](
+var reExtractExportedFunction = regexp.MustCompile(`^function\s+(\w+)\s*\(`)
+
+// extracts an exported symbol of kind local function [identifier](
+var reExtractExportedLocalFunction = regexp.MustCompile(`^local\s+function\s+(\w+)\s*\(`)
+
+// given a comma-separated list of identifiers, finds each identifier within
+var reExtractSymbols = regexp.MustCompile(`\b\w+\b`)
+
+// matches the lines that continue the top level statement before them, like indented lines and 'end'
+var reContinuesDeclaration = regexp.MustCompile(`^(?:\s|(?:end|else|elseif|until)\b|[)\]}])`)
+
+// determines if the given line matches the structure needed to be a prelude comment
+var reIsPreludeComment = regexp.MustCompile(`^--\s*\w+\s*:`)
+
+// detects a macro, which is --# followed by its name, so that a line like --#------------ stays a comment
+var reIsMacro = regexp.MustCompile(`^\s*--#\s*\w`)
+
+var reGetMacroType = regexp.MustCompile(`--#\s*(\w+)`)
+var reGetMacroArgs = regexp.MustCompile(`--#\s*\w+\s+(.*)$`)
+var reBreakDownMacroArgs = regexp.MustCompile(`\S+`)
+
+// the first \w+ is the --# STRING text. The first capturing group gets the string name,
+// and the second capturing group gets the string contents
+var reGetMacroStringDeclarationArgs = regexp.MustCompile(`--#\s*\w+\s+(\w+)\s+(.*)$`)
+
+var reIdentifiers = regexp.MustCompile(`\w+`)
+
+// blobDecoder is the function that decodes the strings made by blob.Pack, which is written into the code once
+const blobDecoder = `function ticc_unblob(s)
+ local d,o,a,b,i={},{},0,0,1
+ for k=1,#s do
+  a=(a<<6)|(s:byte(k)-48) b=b+6
+  if b>=8 then b=b-8 d[#d+1]=a>>b a=a&((1<<b)-1) end
+ end
+ while i<=#d do
+  local c=d[i] i=i+1
+  if c<128 then
+   for k=0,c do o[#o+1]=d[i] i=i+1 end
+  else
+   for k=1,c-125 do o[#o+1]=d[i] end i=i+1
+  end
+ end
+ return o
+end`
+
+// literalStyle describes how values are written as lua table constructors
+var literalStyle = literal.Style{
+	Nil:       "nil",
+	True:      "true",
+	False:     "false",
+	ListOpen:  "{",
+	ListClose: "}",
+	MapOpen:   "{",
+	MapClose:  "}",
+	Separator: ",",
+	Key: func(key string) string {
+		if literal.IsIdentifier(key) && !keywords[key] {
+			return key + "="
+		}
+		return "[" + literal.Escape(key, '"', "") + "]="
+	},
+	Quote: func(s string) string {
+		return literal.Escape(s, '"', "")
+	},
+}
+
+// keywords cannot be used as bare keys in a table constructor
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true, "false": true,
+	"for": true, "function": true, "goto": true, "if": true, "in": true, "local": true, "nil": true,
+	"not": true, "or": true, "repeat": true, "return": true, "then": true, "true": true, "until": true,
+	"while": true,
+}
+
+// StripUnimportant returns a new line which is the result of stripping all the unimportant or non-usable
+// characters from it. This includes stripping away unneeded whitespace, comments, and any text that comes after comments.
+// The compiler uses StripBlocks instead, which knows about the comments and strings that span several lines.
+func (ls LuaLanguageService) StripUnimportant(line string) string {
+	stripped, _ := ls.StripBlocks(line, nil)
+	return stripped
+}
+
+// IsLineImport determines whether a line of code contains an import statement. In lua, this is the 'require' token.
+func (ls LuaLanguageService) IsLineImport(line string) bool {
+	matched, _ := regexp.MatchString(`\brequire\b`, line)
+	return matched
+}
+
+// GetImportData will extract a slice of imported symbols and the relative path to the file that is being imported given
+// a line of code with an import statement
+func (ls LuaLanguageService) GetImportData(line string) (compiler.ImportData, error) {
+
+	matchInfo := reImportExtract.FindStringSubmatch(line)
+
+	if len(matchInfo) != 3 {
+		// this means the line does not match the template
+		// local <symbol> = require "<path>"
+
+		// check if the line is a bare require without any imports (like 'require "defines"')
+		if requiredFile := reRequireFile.FindStringSubmatch(line); len(requiredFile) > 0 {
+			return compiler.ImportData{
+				Path: requirePathToFile(requiredFile[1]),
+			}, nil
+		}
+
+		return compiler.ImportData{}, errors.New(`import line does not match the templates: 'local {symbol} = require "{importpath}"' or 'require "{importpath}"'`)
+	}
+
+	importData := compiler.ImportData{
+		Symbols: matchInfo[1:2],
+		Path:    requirePathToFile(matchInfo[2]),
+	}
+
+	return importData, nil
+}
+
+// requirePathToFile converts a lua module path like "entities.player" into the relative path of its file
+func requirePathToFile(modulePath string) string {
+	modulePath = strings.TrimSuffix(modulePath, ".lua")
+	return strings.ReplaceAll(modulePath, ".", "/") + ".lua"
+}
+
+// IsExportDeclaration determines if a line contains a global declaration that should be available to other files
+// importing this one
+func (ls LuaLanguageService) IsExportDeclaration(line string) bool {
+	// in lua, the following are export declarations
+	// * Entity = ...
+	// * function Entity(...)
+	// * local function Entity(...)
+	// and they must all have zero leading indentation. Because every file is stitched into the same chunk,
+	// a top-level local function is still visible to the files that come after it.
+
+	if reExtractExportedSymbols.MatchString(line) {
+		return true
+	}
+
+	if reExtractExportedFunction.MatchString(line) {
+		return true
+	}
+
+	if reExtractExportedLocalFunction.MatchString(line) {
+		return true
+	}
+
+	return false
+}
+
+// GetExportDeclarations extracts a list of exported symbols from the line
+func (ls LuaLanguageService) GetExportDeclarations(line string) []string {
+
+	matchInfo := reExtractExportedSymbols.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return reExtractSymbols.FindAllString(matchInfo[1], -1)
+	}
+
+	matchInfo = reExtractExportedFunction.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return matchInfo[1:2]
+	}
+
+	matchInfo = reExtractExportedLocalFunction.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return matchInfo[1:2]
+	}
+
+	return []string{}
+}
+
+// ContinuesDeclaration determines if a line continues the top level declaration on the lines before it, so that the
+// declaration can be left out as a whole when it is not used
+func (ls LuaLanguageService) ContinuesDeclaration(statement []string, line string) bool {
+	return reContinuesDeclaration.MatchString(line)
+}
+
+// ExtractPrelude extracts a string from the supplied main file code. This string is the prelude-
+// a set of comments that must appear at the top of a file used by the TIC-80 to determine the title,
+// author, description, language, and input type of the game.
+//
+// Normally, comments are stripped out by
+// StripUnimportant, which is why this needs to be its own separate method
+func (ls LuaLanguageService) ExtractPrelude(mainFileCode string) string {
+	result := ""
+	// split the code into lines
+	lines := strings.Split(mainFileCode, "\n")
+	for _, line := range lines {
+		if reIsPreludeComment.MatchString(line) {
+			result = result + line + "\n"
+		} else {
+			break
+		}
+	}
+	return result
+}
+
+// FormatLiteral writes a value built by the literal package as a lua table constructor, string, or other literal
+func (ls LuaLanguageService) FormatLiteral(value interface{}) string {
+	return literal.Format(value, literalStyle)
+}
+
+// FormatDeclaration writes a global assignment that makes the value available to the whole program
+func (ls LuaLanguageService) FormatDeclaration(name string, value string) string {
+	return name + " = " + value
+}
+
+// BlobDecoder writes the function that turns the strings made by blob.Pack back into bytes, as a global lua function.
+// The bytes come back as a table indexed from 1.
+func (ls LuaLanguageService) BlobDecoder() []string {
+	return strings.Split(blobDecoder, "\n")
+}
+
+// FormatBlobDecode writes a call to the function from BlobDecoder
+func (ls LuaLanguageService) FormatBlobDecode(packed string) string {
+	return "ticc_unblob(" + ls.FormatLiteral(packed) + ")"
+}
+
+// SubstituteDefines takes in a line of code and the current set of previously-declared defines. It then
+// detects any occurences of the defines that should be replaced and returns a string with these occurences
+// replaced by their correct definitions.
+func (ls LuaLanguageService) SubstituteDefines(line string, defines map[string]string) string {
+	return reIdentifiers.ReplaceAllStringFunc(line, func(identifier string) string {
+		replacement, isDefined := defines[identifier]
+		if !isDefined {
+			return identifier
+		}
+		return replacement
+	})
+}
+
+// IsLineMacro determines if the given line constitutes a macro declaration of some sort
+func (ls LuaLanguageService) IsLineMacro(line string) bool {
+	return reIsMacro.MatchString(line)
+}
+
+// GetMacroType will determine what type of macro a given line is, provided that it has been
+// detected previously by IsLineMacro.
+func (ls LuaLanguageService) GetMacroType(line string) compiler.MacroType {
+	matchInfo := reGetMacroType.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return compiler.MacroTypeUnknown
+	}
+
+	symbol := matchInfo[1]
+
+	switch strings.ToUpper(symbol) {
+	case "DEFINE":
+		return compiler.MacroTypeDefine
+	case "STRING":
+		return compiler.MacroTypeString
+	case "DATA":
+		return compiler.MacroTypeData
+	case "EMBED":
+		return compiler.MacroTypeEmbed
+	case "BLOB":
+		return compiler.MacroTypeBlob
+	case "IF":
+		return compiler.MacroTypeIf
+	case "ELSEIF":
+		return compiler.MacroTypeElseIf
+	case "ELSE":
+		return compiler.MacroTypeElse
+	case "ENDIF":
+		return compiler.MacroTypeEndIf
+	default:
+		return compiler.MacroTypeUnknown
+	}
+}
+
+// GetMacroArgs will return a slice of all the space-separated values that follow a
+// macro definition
+func (ls LuaLanguageService) GetMacroArgs(line string) []string {
+	matchInfo := reGetMacroArgs.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return []string{}
+	}
+
+	fullArgs := matchInfo[1]
+
+	return reBreakDownMacroArgs.FindAllString(fullArgs, -1)
+}
+
+// GetMacroStringDeclaration extracts the name and the contents of a string macro
+func (ls LuaLanguageService) GetMacroStringDeclaration(line string) (string, string, error) {
+	matchInfo := reGetMacroStringDeclarationArgs.FindStringSubmatch(line)
+
+	// the first string in matchInfo is always the full matched text
+	// the second string is the string name
+	// the third string is the string contents
+	if len(matchInfo) != 3 {
+		return "", "", errors.New("invalid format for string macro. must be --#string [STRING_NAME] STRING CONTENTS")
+	}
+
+	return matchInfo[1], matchInfo[2], nil
+}
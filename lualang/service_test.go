@@ -0,0 +1,27 @@
+package lualang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func TestIsLineMacro(t *testing.T) {
+	ls := LuaLanguageService{}
+
+	for _, line := range []string{"--#define SPEED 2", "  --# if DEBUG", "--#ENDIF"} {
+		if !ls.IsLineMacro(line) {
+			t.Errorf("expected %q to be a macro", line)
+		}
+	}
+
+	for _, line := range []string{"--#-------------", "--#", "  --#   ", "-- #define SPEED 2"} {
+		if ls.IsLineMacro(line) {
+			t.Errorf("expected %q to be a comment", line)
+		}
+	}
+
+	if macroType := ls.GetMacroType("--#-------------"); macroType != compiler.MacroTypeUnknown {
+		t.Errorf("expected a line without a macro name to be an unknown macro, got %v", macroType)
+	}
+}
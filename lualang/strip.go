@@ -0,0 +1,133 @@
+package lualang
+
+import (
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+// StripBlocks strips the comments and the trailing whitespace from a line like StripUnimportant, keeping track of the
+// long comments (--[[ ]] and --[==[ ]==]) and long strings ([[ ]]) that span several lines. Quoted strings are skipped,
+// so a -- inside of one is not mistaken for a comment.
+func (ls LuaLanguageService) StripBlocks(line string, open *compiler.OpenBlock) (string, *compiler.OpenBlock) {
+	var result strings.Builder
+	i := 0
+
+	for i < len(line) {
+		if open != nil {
+			end := strings.Index(line[i:], open.Closing)
+
+			if end < 0 {
+				if !open.IsComment {
+					result.WriteString(line[i:])
+				}
+				// the string is kept exactly as it is, including the whitespace at the end of the line
+				return _trimIfCode(result.String(), open), open
+			}
+
+			end += i + len(open.Closing)
+			if open.IsComment {
+				i = _skipCommentGap(line, end, &result)
+			} else {
+				result.WriteString(line[i:end])
+				i = end
+			}
+			open = nil
+			continue
+		}
+
+		char := line[i]
+
+		switch {
+		case strings.HasPrefix(line[i:], "--"):
+			closing, opening := _longBracket(line[i+2:])
+			if closing == "" {
+				// a comment that goes on to the end of the line
+				return strings.TrimRight(result.String(), " \t\n\r"), nil
+			}
+			open = &compiler.OpenBlock{Closing: closing, IsComment: true}
+			i += 2 + len(opening)
+		case char == '[':
+			closing, opening := _longBracket(line[i:])
+			if closing == "" {
+				result.WriteByte(char)
+				i++
+				continue
+			}
+			open = &compiler.OpenBlock{Closing: closing}
+			result.WriteString(opening)
+			i += len(opening)
+		case char == '"' || char == '\'':
+			end := _skipQuotedString(line, i)
+			result.WriteString(line[i:end])
+			i = end
+		default:
+			result.WriteByte(char)
+			i++
+		}
+	}
+
+	return _trimIfCode(result.String(), open), open
+}
+
+// _trimIfCode trims the whitespace at the end of a line, unless the line ends in the middle of a string
+func _trimIfCode(line string, open *compiler.OpenBlock) string {
+	if open != nil && !open.IsComment {
+		return line
+	}
+	return strings.TrimRight(line, " \t\n\r")
+}
+
+// _skipCommentGap finds where the code goes on after a long comment that ends in the middle of a line. The comment
+// keeps the code on either side of it apart with a space, but is left out entirely if nothing but whitespace comes
+// before it, so that the indentation of the line stays the same.
+func _skipCommentGap(line string, end int, result *strings.Builder) int {
+	if strings.TrimSpace(result.String()) == "" {
+		for end < len(line) && (line[end] == ' ' || line[end] == '\t') {
+			end++
+		}
+		return end
+	}
+
+	if !strings.HasSuffix(result.String(), " ") {
+		result.WriteByte(' ')
+	}
+	return end
+}
+
+// _longBracket finds the closing bracket of a long string or comment like [[ ]] or [==[ ]==] if the text starts
+// with one
+func _longBracket(text string) (closing string, opening string) {
+	if !strings.HasPrefix(text, "[") {
+		return "", ""
+	}
+
+	level := 1
+	for level < len(text) && text[level] == '=' {
+		level++
+	}
+
+	if level >= len(text) || text[level] != '[' {
+		return "", ""
+	}
+
+	equals := strings.Repeat("=", level-1)
+	return "]" + equals + "]", "[" + equals + "["
+}
+
+// _skipQuotedString finds the position right after the quote that closes the string starting at the given position,
+// or the end of the line if the string is never closed. A backslash escapes the character after it.
+func _skipQuotedString(line string, start int) int {
+	quote := line[start]
+
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+
+	return len(line)
+}
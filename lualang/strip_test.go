@@ -0,0 +1,66 @@
+package lualang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func TestStripBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{
+			name:     "line comments",
+			lines:    []string{"x = 1 -- one", "-- only a comment", "  y = 2  "},
+			expected: []string{"x = 1", "", "  y = 2"},
+		},
+		{
+			name:     "dashes inside strings",
+			lines:    []string{`print("a -- b", 'c -- d') -- e`, `s = "\" -- "`},
+			expected: []string{`print("a -- b", 'c -- d')`, `s = "\" -- "`},
+		},
+		{
+			name:     "long comments",
+			lines:    []string{"x = 1 --[[ starts", "code = 2", "ends ]] y = 3", "--[==[", "]] still a comment", "]==]"},
+			expected: []string{"x = 1", "", "y = 3", "", "", ""},
+		},
+		{
+			name:     "long comment in the middle of a line",
+			lines:    []string{"a --[[ note ]]b", "  --[[ note ]] c = 1"},
+			expected: []string{"a b", "  c = 1"},
+		},
+		{
+			name:     "long strings",
+			lines:    []string{"s = [[first  ", "-- not a comment  ", "", "]] .. t -- comment"},
+			expected: []string{"s = [[first  ", "-- not a comment  ", "", "]] .. t"},
+		},
+		{
+			name:     "indexing is not a long string",
+			lines:    []string{"a[b[1]] = 2 -- comment"},
+			expected: []string{"a[b[1]] = 2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ls := LuaLanguageService{}
+			var open *compiler.OpenBlock
+
+			for i, line := range test.lines {
+				var stripped string
+				stripped, open = ls.StripBlocks(line, open)
+
+				if stripped != test.expected[i] {
+					t.Errorf("line %d: expected %q, got %q", i+1, test.expected[i], stripped)
+				}
+			}
+
+			if open != nil {
+				t.Errorf("expected everything to be closed, but %q is still open", open.Closing)
+			}
+		})
+	}
+}
@@ -3,13 +3,21 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/radovskyb/watcher"
 
 	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/fennellang"
+	"github.com/novemberisms/ticc/jslang"
+	"github.com/novemberisms/ticc/lualang"
 	"github.com/novemberisms/ticc/moonlang"
+	"github.com/novemberisms/ticc/pythonlang"
+	"github.com/novemberisms/ticc/rubylang"
+	"github.com/novemberisms/ticc/squirrellang"
 	"github.com/novemberisms/ticc/wrenlang"
 )
 
@@ -20,6 +28,18 @@ func main() {
 		}
 	}()
 
+	// subcommands have their own arguments, so they are dispatched before the usual ones are parsed
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "extract":
+			extractCart(os.Args[2:])
+			return
+		case "inspect":
+			inspectCart(os.Args[2:])
+			return
+		}
+	}
+
 	// populate the Args global var with the proper command line args
 	getArguments()
 
@@ -62,10 +82,14 @@ func main() {
 				// and cause a loop. So we ignore the output file here
 
 				// also ignore any changes to files with a different file extension
-				// than the chosen language as it could be the output .tic file itself
-				// or possibly the sprite or sound data
+				// than the chosen language or the asset files, as it could be the
+				// output .tic file itself
+
+				if event.Name() == path.Base(Args.outputFile) {
+					continue
+				}
 
-				if event.Name() != path.Base(Args.outputFile) && path.Ext(event.Name()) == fmt.Sprintf(".%s", Args.language) {
+				if path.Ext(event.Name()) == fmt.Sprintf(".%s", Args.language) || isAssetFile(event.Name()) {
 					doCompilation()
 					fmt.Printf("--------------------------------------------\n")
 				}
@@ -92,10 +116,22 @@ func doCompilation() {
 	var langService compiler.LangService
 
 	switch Args.language {
+	case lua:
+		langService = lualang.LuaLanguageService{}
 	case moon:
 		langService = moonlang.MoonscriptLanguageService{}
 	case wren:
 		langService = wrenlang.WrenLanguageService{}
+	case js:
+		langService = jslang.JavascriptLanguageService{}
+	case fennel:
+		langService = fennellang.FennelLanguageService{}
+	case squirrel:
+		langService = squirrellang.SquirrelLanguageService{}
+	case ruby:
+		langService = rubylang.RubyLanguageService{}
+	case python:
+		langService = pythonlang.PythonLanguageService{}
 	default:
 		checkError(errors.New("language not yet implemented"))
 	}
@@ -104,19 +140,81 @@ func doCompilation() {
 	comp := compiler.NewCompiler(
 		langService,
 		mainFile,
-		Args.outputFile,
 		Args.directory.Name(),
 		Args.defines,
+		compiler.Options{
+			Minify:    Args.minify,
+			Mangle:    Args.mangle,
+			TreeShake: Args.release,
+		},
 	)
 
 	fmt.Println("Compiling...")
 
-	err = comp.Start()
+	// the assets go into the cartridge before the code is compiled, since some of them generate code. Plain code
+	// without any data leaves them out, so a broken asset file can't break it.
+	tic, err := loadOutputCart()
+
+	if err == nil && outputHasData() {
+		err = importAssets(tic, comp)
+	}
+
+	if err == nil {
+		err = comp.Start()
+	}
+
+	if err == nil {
+		err = _reportSizes(comp)
+	}
+
+	if err == nil {
+		err = writeOutput(tic, comp.Output())
+	}
 
 	if err != nil {
 		fmt.Println(err.Error())
-	} else {
-		fmt.Println("OK")
+		return
+	}
+
+	for _, report := range comp.Blobs() {
+		ratio := 100.0
+		if report.RawSize > 0 {
+			ratio = float64(report.PackedSize) * 100 / float64(report.RawSize)
+		}
+		fmt.Printf("blob: %s (%s) %d bytes -> %d characters (%.1f%%)\n", report.Name, report.Path, report.RawSize, report.PackedSize, ratio)
+	}
+
+	for _, report := range comp.Removed() {
+		fmt.Printf("removed: %s %d bytes (%s)\n", report.Path, report.Bytes, strings.Join(report.Symbols, ", "))
+	}
+
+	fmt.Println("OK")
+
+}
+
+// _reportSizes prints how many bytes of code each file contributes, after any stripping and minification, and fails
+// if the code is larger than -max-size. The TIC-80 limits the size of the code in bytes, so a character outside of
+// ascii counts more than once.
+func _reportSizes(comp *compiler.Compiler) error {
+	limit := codeSizeLimit
+	if Args.maxSize > 0 {
+		limit = Args.maxSize
+	}
+
+	for _, report := range comp.Sizes() {
+		name := report.Path
+		if name == "" {
+			name = "(generated)"
+		}
+		fmt.Printf("size: %s %d bytes (%.1f%%)\n", name, report.Size, float64(report.Size)*100/float64(limit))
+	}
+
+	total := len(comp.Output())
+	fmt.Printf("code: %d / %d bytes (%.1f%%)\n", total, limit, float64(total)*100/float64(limit))
+
+	if Args.maxSize > 0 && total > Args.maxSize {
+		return fmt.Errorf("the code is %d bytes over the -max-size of %d", total-Args.maxSize, Args.maxSize)
 	}
 
+	return nil
 }
@@ -0,0 +1,251 @@
+package moonlang
+
+import (
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+// the globals of lua and the names its metatables and moonscript classes use, which code can use without declaring
+var builtinNames = []string{
+	"self", "super", "_G", "_ENV", "_VERSION", "arg", "assert", "bit32", "collectgarbage", "coroutine", "debug",
+	"dofile", "error", "getfenv", "getmetatable", "io", "ipairs", "load", "loadfile", "loadstring", "math", "module",
+	"next", "os", "package", "pairs", "pcall", "print", "rawequal", "rawget", "rawlen", "rawset", "require", "select",
+	"setfenv", "setmetatable", "string", "table", "tonumber", "tostring", "type", "unpack", "utf8", "xpcall",
+	"new", "__base", "__class", "__index", "__inherited", "__init", "__name", "__parent",
+}
+
+// what a piece of the code is
+type tokenKind int
+
+const (
+	// a token of the code itself
+	tokenCode tokenKind = iota
+	// whitespace, a line break or a comment
+	tokenSpace
+	// the rest of a string that started on an earlier line
+	tokenString
+)
+
+type token struct {
+	text string
+	kind tokenKind
+}
+
+// Mangle renames the identifiers declared in the code to shorter ones. Since they are renamed everywhere they appear
+// no matter what scope they are in, only the names that are always variables are renamed: the ones declared by an
+// assignment, a local, a for loop, a class or a parameter list, which are never used as the key of a table (like
+// in a.name, @name, a\name or {name: 1}) or in the interpolation of a string.
+func (ls MoonscriptLanguageService) Mangle(code string, reserved map[string]bool) (string, error) {
+	tokens := _splitTokens(code)
+
+	declared := make(map[string]bool)
+	excluded := make(map[string]bool)
+	taken := make(map[string]bool)
+
+	for name := range keywords {
+		taken[name] = true
+	}
+	// the new names can't shadow the reserved ones either, even where those are not used
+	for name := range reserved {
+		excluded[name], taken[name] = true, true
+	}
+	for _, name := range builtinNames {
+		excluded[name], taken[name] = true, true
+	}
+
+	line := []string{}
+	for _, t := range tokens {
+		switch {
+		case t.kind == tokenCode:
+			line = append(line, t.text)
+			if _isWordChar(t.text[0]) {
+				taken[t.text] = true
+			}
+			if t.text[0] == '"' {
+				_findInterpolatedNames(t.text, excluded)
+			}
+		case t.kind == tokenString:
+			_findInterpolatedNames(t.text, excluded)
+		case strings.Contains(t.text, "\n"):
+			_findDeclarations(line, declared, excluded)
+			line = line[:0]
+		}
+	}
+	_findDeclarations(line, declared, excluded)
+
+	counts := make(map[string]int)
+	for _, t := range tokens {
+		if t.kind == tokenCode && declared[t.text] && !excluded[t.text] {
+			counts[t.text]++
+		}
+	}
+
+	names := compiler.ShortNames(counts, taken)
+
+	var result strings.Builder
+	for _, t := range tokens {
+		if name, renamed := names[t.text]; renamed && t.kind == tokenCode {
+			result.WriteString(name)
+		} else {
+			result.WriteString(t.text)
+		}
+	}
+
+	return result.String(), nil
+}
+
+// _splitTokens splits the code into tokens, which make up the code again when they are put back together
+func _splitTokens(code string) []token {
+	tokens := []token{}
+	var open *openString
+
+	for i, line := range strings.Split(code, "\n") {
+		if i > 0 {
+			tokens = append(tokens, token{"\n", tokenSpace})
+		}
+
+		start := 0
+		if open != nil {
+			end, closed := _scanStringBody(line, 0, open.closing)
+			tokens = append(tokens, token{line[:end], tokenString})
+			if !closed {
+				continue
+			}
+			open, start = nil, end
+		}
+
+		for j := start; j < len(line); {
+			switch {
+			case line[j] == ' ' || line[j] == '\t' || line[j] == '\r':
+				end := j
+				for end < len(line) && (line[end] == ' ' || line[end] == '\t' || line[end] == '\r') {
+					end++
+				}
+				tokens = append(tokens, token{line[j:end], tokenSpace})
+				j = end
+			case strings.HasPrefix(line[j:], "--"):
+				tokens = append(tokens, token{line[j:], tokenSpace})
+				j = len(line)
+			default:
+				text, closing := _nextToken(line, j)
+				tokens = append(tokens, token{text, tokenCode})
+				j += len(text)
+				if closing != "" {
+					open = &openString{closing}
+				}
+			}
+		}
+	}
+
+	return tokens
+}
+
+// _findInterpolatedNames excludes every name inside the interpolations of a string from being renamed, since the
+// string is kept as it is
+func _findInterpolatedNames(text string, excluded map[string]bool) {
+	for i := strings.Index(text, "#{"); i >= 0; i = strings.Index(text, "#{") {
+		end := _skipInterpolation(text, i+2)
+		for _, name := range reIdentifiers.FindAllString(text[i+2:end], -1) {
+			excluded[name] = true
+		}
+		text = text[end:]
+	}
+}
+
+// _findDeclarations finds the names that a line of code declares, and the ones it uses as the keys of tables
+func _findDeclarations(line []string, declared map[string]bool, excluded map[string]bool) {
+	for i, text := range line {
+		if !_isWordChar(text[0]) {
+			continue
+		}
+
+		previous, next := "", ""
+		if i > 0 {
+			previous = line[i-1]
+		}
+		if i+1 < len(line) {
+			next = line[i+1]
+		}
+
+		switch {
+		case previous == "." || previous == "\\" || previous == "@" || previous == "@@" || previous == ":" || next == ":":
+			excluded[text] = true
+		case previous == "class" || previous == "for" || previous == "," && _isLoopVariable(line, i):
+			declared[text] = true
+		case previous == "(" || previous == ",":
+			if _isParameter(line, i) {
+				declared[text] = true
+			}
+		}
+	}
+
+	// the names that an import takes out of a table are its keys
+	if len(line) > 0 && line[0] == "import" {
+		for _, text := range line[1:] {
+			if text == "from" {
+				break
+			}
+			excluded[text] = true
+		}
+	}
+
+	// the names assigned to at the start of the line, as in 'a, b = 1, 2' or 'local a'
+	start := 0
+	if len(line) > 0 && (line[0] == "local" || line[0] == "export") {
+		start = 1
+	}
+	names := []string{}
+	for i := start; i < len(line); i += 2 {
+		if !_isWordChar(line[i][0]) || keywords[line[i]] {
+			return
+		}
+		names = append(names, line[i])
+
+		isLast := i+1 == len(line)
+		if isLast && line[0] == "local" || !isLast && line[i+1] == "=" {
+			for _, name := range names {
+				declared[name] = true
+			}
+			return
+		}
+		if isLast || line[i+1] != "," {
+			return
+		}
+	}
+}
+
+// _isLoopVariable determines if the name at the given position comes after the first variable of a for loop, as
+// the v of 'for k, v in pairs t'
+func _isLoopVariable(line []string, index int) bool {
+	for i := index - 1; i >= 0; i -= 2 {
+		if line[i] != "," || i == 0 || !_isWordChar(line[i-1][0]) {
+			return false
+		}
+		if i >= 2 && line[i-2] == "for" {
+			return true
+		}
+	}
+	return false
+}
+
+// _isParameter determines if the name at the given position is in the parameter list of a function, by finding the
+// parenthesis that closes the list and checking that an arrow follows it
+func _isParameter(line []string, index int) bool {
+	depth := 0
+
+	for i := index; i < len(line); i++ {
+		switch line[i] {
+		case "(", "{", "[":
+			depth++
+		case ")", "}", "]":
+			if depth > 0 {
+				depth--
+				continue
+			}
+			return i+1 < len(line) && (line[i+1] == "->" || line[i+1] == "=>")
+		}
+	}
+
+	return false
+}
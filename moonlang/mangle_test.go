@@ -0,0 +1,86 @@
+package moonlang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func _reservedNames(names ...string) map[string]bool {
+	reserved := make(map[string]bool)
+	for _, name := range compiler.TICNames {
+		reserved[name] = true
+	}
+	for _, name := range names {
+		reserved[name] = true
+	}
+	return reserved
+}
+
+func TestMangle(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "declared names",
+			input:    "counter = 0\ncounter += 1",
+			expected: "a = 0\na += 1",
+		},
+		{
+			name:     "names of lua and the TIC-80 that are shadowed",
+			input:    "dofile = 1\nloadstring = 2\nprint = 3\npaint = 4\nfft = 5",
+			expected: "dofile = 1\nloadstring = 2\nprint = 3\npaint = 4\nfft = 5",
+		},
+		{
+			name:     "reserved names",
+			input:    "Player = 1\nlocal speed = Player",
+			expected: "Player = 1\nlocal a = Player",
+		},
+		{
+			name:     "keys of tables",
+			input:    "width = 1\nheight = 2\nsize = {:width, height: height}",
+			expected: "width = 1\nheight = 2\na = {:width, height: height}",
+		},
+		{
+			name:     "keys after a dot or a backslash",
+			input:    "left = 1\nobj.left = left\nobj\\left!",
+			expected: "left = 1\nobj.left = left\nobj\\left!",
+		},
+		{
+			name:     "interpolation",
+			input:    "total = 1\nother = 2\nlabel = \"#{total}\"\nx = other",
+			expected: "total = 1\na = 2\nb = \"#{total}\"\nx = a",
+		},
+		{
+			name:     "for loops",
+			input:    "for index, value in ipairs list\n  trace index, value\nfor step = 1, 10\n  trace step",
+			expected: "for a, c in ipairs list\n  trace a, c\nfor b = 1, 10\n  trace b",
+		},
+		{
+			name:     "parameters",
+			input:    "add = (left, right) -> left + right",
+			expected: "c = (a, b) -> a + b",
+		},
+		{
+			name:     "strings are left as they are",
+			input:    "value = \"value\"\nother = [[value]]",
+			expected: "b = \"value\"\na = [[value]]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mangled, err := MoonscriptLanguageService{}.Mangle(test.input, _reservedNames("Player"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if mangled != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, mangled)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package moonlang
+
+import (
+	"strings"
+)
+
+// the tokens of more than one character that are made of punctuation, longest first so they are matched before their
+// prefixes
+var multiCharOperators = []string{
+	"...", "..=",
+	"..", "->", "=>", "==", "~=", "!=", "<=", ">=", "+=", "-=", "*=", "/=", "%=", "^=", "<<", ">>", "@@",
+}
+
+// keywords that can be followed directly by an expression. A space between them and an opening parenthesis or a
+// minus is never a function call the way it would be after a name.
+var expressionKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "if": true, "unless": true, "elseif": true, "while": true, "for": true,
+	"in": true, "return": true, "switch": true, "when": true, "with": true, "then": true, "do": true, "else": true,
+	"extends": true, "from": true, "import": true, "export": true, "local": true, "using": true, "class": true,
+}
+
+// the tokens that mean something different when there is a space in front of them, as the start of an argument of
+// a function call without parentheses ('f -x' calls f with -x, while 'f-x' subtracts x from f)
+var spaceSensitiveTokens = map[string]bool{
+	"(": true, "[": true, "{": true, "-": true, "#": true, "@": true, "@@": true, "!": true, "\\": true, ":": true,
+	".": true, "...": true,
+}
+
+// the number of spaces a tab counts for in the indentation, the same as the moonscript compiler
+const tabWidth = 4
+
+// a string that is still open at the end of a line, which carries on into the next one
+type openString struct {
+	// the quote that closes the string, or the closing bracket of a long string like ]==]
+	closing string
+}
+
+// Minify shrinks the code while keeping its meaning:
+//
+//   - blank lines and comments are dropped
+//   - every level of indentation becomes a single space, keeping the blocks that moonscript finds from it
+//   - spaces are removed around operators and punctuation, except where they tell a function call without
+//     parentheses apart from an expression (as in 'f -x' and 'f - x')
+//
+// Strings are kept exactly as they are, including the ones that span several lines.
+func (ls MoonscriptLanguageService) Minify(code string) (string, error) {
+	var result strings.Builder
+
+	indents := []int{0}
+	var open *openString
+
+	for _, line := range strings.Split(code, "\n") {
+		var minified string
+
+		if open != nil {
+			// the rest of a string that started on an earlier line, which must not be touched at all
+			end, closed := _scanStringBody(line, 0, open.closing)
+			if !closed {
+				result.WriteString(line + "\n")
+				continue
+			}
+			open = nil
+			rest, stillOpen := _minifyTokens(line[end:])
+			minified, open = line[:end]+rest, stillOpen
+		} else {
+			trimmed := strings.TrimLeft(line, " \t")
+			rest, stillOpen := _minifyTokens(trimmed)
+			open = stillOpen
+
+			if rest == "" {
+				continue
+			}
+
+			level := _indentLevel(&indents, _indentWidth(line[:len(line)-len(trimmed)]))
+			minified = strings.Repeat(" ", level) + rest
+		}
+
+		result.WriteString(minified + "\n")
+	}
+
+	return result.String(), nil
+}
+
+// _indentWidth counts the width of the whitespace at the start of a line
+func _indentWidth(whitespace string) int {
+	width := 0
+	for _, char := range whitespace {
+		if char == '\t' {
+			width += tabWidth
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// _indentLevel finds how many blocks deep a line with the given indentation is, given the indentation of the blocks
+// that are open so far
+func _indentLevel(indents *[]int, width int) int {
+	stack := *indents
+
+	for len(stack) > 1 && stack[len(stack)-1] > width {
+		stack = stack[:len(stack)-1]
+	}
+
+	if stack[len(stack)-1] < width {
+		stack = append(stack, width)
+	}
+
+	*indents = stack
+
+	return len(stack) - 1
+}
+
+// _minifyTokens removes every space from a line that does not change its meaning, along with any comment. If the line
+// ends in the middle of a string, the string is returned as well.
+func _minifyTokens(line string) (string, *openString) {
+	var result strings.Builder
+
+	// the last token written, and whether there was whitespace after it
+	previous := ""
+	spaced := false
+
+	for i := 0; i < len(line); {
+		char := line[i]
+
+		if char == ' ' || char == '\t' {
+			spaced = true
+			i++
+			continue
+		}
+
+		if strings.HasPrefix(line[i:], "--") {
+			break
+		}
+
+		token, closing := _nextToken(line, i)
+
+		if spaced && previous != "" && _needsSpace(previous, token, line, i+len(token)) {
+			result.WriteByte(' ')
+		}
+
+		result.WriteString(token)
+		previous, spaced = token, false
+		i += len(token)
+
+		if closing != "" {
+			// the string goes on past the end of the line
+			return result.String(), &openString{closing}
+		}
+	}
+
+	return result.String(), nil
+}
+
+// _nextToken reads the token at the given position. If it is a string that is not closed by the end of the line,
+// the text that will close it is returned as well.
+func _nextToken(line string, start int) (string, string) {
+	char := line[start]
+
+	switch {
+	case char == '"' || char == '\'':
+		end, closed := _scanStringBody(line, start+1, string(char))
+		if !closed {
+			return line[start:], string(char)
+		}
+		return line[start:end], ""
+	case char == '[':
+		if closing, opening := _longBracket(line[start:]); closing != "" {
+			end, closed := _scanStringBody(line, start+len(opening), closing)
+			if !closed {
+				return line[start:], closing
+			}
+			return line[start:end], ""
+		}
+	case _isWordChar(char):
+		end := start
+		for end < len(line) && (_isWordChar(line[end]) || _isNumberContinuation(line, start, end)) {
+			end++
+		}
+		return line[start:end], ""
+	}
+
+	for _, operator := range multiCharOperators {
+		if strings.HasPrefix(line[start:], operator) {
+			return operator, ""
+		}
+	}
+
+	return line[start : start+1], ""
+}
+
+// _isNumberContinuation determines if the character at end continues the number that starts at start, like the
+// decimal point of 1.5 or the sign of the exponent in 1e-5
+func _isNumberContinuation(line string, start int, end int) bool {
+	if line[start] < '0' || line[start] > '9' {
+		return false
+	}
+
+	switch line[end] {
+	case '.':
+		// 1..2 is a concatenation rather than a decimal point
+		return end+1 < len(line) && line[end+1] != '.'
+	case '-', '+':
+		previous := line[end-1]
+		isHex := len(line) > start+1 && (line[start+1] == 'x' || line[start+1] == 'X')
+		return !isHex && (previous == 'e' || previous == 'E')
+	}
+
+	return false
+}
+
+// _longBracket finds the closing bracket of a long string like [[ ]] or [==[ ]==] if the text starts with one
+func _longBracket(text string) (closing string, opening string) {
+	level := 1
+	for level < len(text) && text[level] == '=' {
+		level++
+	}
+
+	if level >= len(text) || text[level] != '[' {
+		return "", ""
+	}
+
+	equals := strings.Repeat("=", level-1)
+	return "]" + equals + "]", "[" + equals + "["
+}
+
+// _scanStringBody finds the end of a string whose body starts at the given position, returning the position right
+// after its closing text. Quoted strings can escape their quote with a backslash, and double quoted strings can
+// interpolate code with #{}, which may hold strings of its own.
+func _scanStringBody(line string, start int, closing string) (int, bool) {
+	isLong := len(closing) > 1 || closing == "]"
+
+	for i := start; i < len(line); i++ {
+		if strings.HasPrefix(line[i:], closing) {
+			return i + len(closing), true
+		}
+
+		if isLong {
+			continue
+		}
+
+		switch {
+		case line[i] == '\\':
+			// the escaped character can't close the string
+			i++
+		case closing == `"` && strings.HasPrefix(line[i:], "#{"):
+			i = _skipInterpolation(line, i+2) - 1
+		}
+	}
+
+	return len(line), false
+}
+
+// _skipInterpolation finds the position right after the brace that closes an interpolation in a double quoted string
+func _skipInterpolation(line string, start int) int {
+	depth := 1
+
+	for i := start; i < len(line); i++ {
+		switch line[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"', '\'':
+			end, _ := _scanStringBody(line, i+1, string(line[i]))
+			i = end - 1
+		}
+	}
+
+	return len(line)
+}
+
+// _needsSpace determines if the whitespace between two tokens has to be kept as a single space. The text after the
+// next token is needed to tell a binary minus from a unary one.
+func _needsSpace(previous string, next string, line string, afterNext int) bool {
+	lastChar := previous[len(previous)-1]
+	firstChar := next[0]
+
+	// two words would run together into one
+	if _isWordChar(lastChar) && _isWordChar(firstChar) {
+		return true
+	}
+
+	// a number followed by a dot would read it as a decimal point
+	if previous[0] >= '0' && previous[0] <= '9' && firstChar == '.' {
+		return true
+	}
+
+	// the two tokens would read as a comment, a long string or a longer operator
+	joined := string(lastChar) + string(firstChar)
+	if joined == "--" || joined == "[[" || joined == "[=" {
+		return true
+	}
+	for _, operator := range multiCharOperators {
+		if strings.HasPrefix(operator, joined) {
+			return true
+		}
+	}
+
+	// strings are arguments too, as in 'print "hello"'
+	isArgument := spaceSensitiveTokens[next] || firstChar == '"' || firstChar == '\'' || firstChar == '['
+	if !isArgument || !_endsValue(previous) {
+		return false
+	}
+
+	// 'a - b' is a subtraction either way, but 'a -b' is a call
+	if next == "-" && afterNext < len(line) && (line[afterNext] == ' ' || line[afterNext] == '\t') {
+		return false
+	}
+
+	return true
+}
+
+// _endsValue determines if a token can be the end of a value that a function call without parentheses could be made on
+func _endsValue(token string) bool {
+	switch {
+	case token == ")" || token == "]" || token == "}" || token == "!" || token == "@" || token == "@@":
+		return true
+	case token[0] == '"' || token[0] == '\'' || token[0] == '[':
+		// a string
+		return len(token) > 1
+	case _isWordChar(token[0]):
+		return !expressionKeywords[token]
+	}
+	return false
+}
+
+func _isWordChar(char byte) bool {
+	return char == '_' || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || char >= 0x80
+}
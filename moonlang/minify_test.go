@@ -0,0 +1,78 @@
+package moonlang
+
+import (
+	"testing"
+)
+
+func TestMinify(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "a unary minus is an argument",
+			input:    "f -x",
+			expected: "f -x\n",
+		},
+		{
+			name:     "a binary minus is a subtraction",
+			input:    "f - x\na-b",
+			expected: "f-x\na-b\n",
+		},
+		{
+			name:     "a number before a concatenation",
+			input:    "x = 1 ..2\ny = 1.5 .. z\nn = 1..2",
+			expected: "x=1 ..2\ny=1.5 ..z\nn=1..2\n",
+		},
+		{
+			name:     "strings as arguments",
+			input:    "print \"x\"\nprint 'y', [[z]]",
+			expected: "print \"x\"\nprint 'y',[[z]]\n",
+		},
+		{
+			name:     "calls without arguments and tables",
+			input:    "f!   -- comment\ng = {1, 2,  - 3}\nh = (x) -> x   +   1",
+			expected: "f!\ng={1,2,-3}\nh=(x)->x+1\n",
+		},
+		{
+			name:     "long strings over several lines",
+			input:    "s = [[line one\n  keep   this -- too\n\n]]   ..   t",
+			expected: "s=[[line one\n  keep   this -- too\n\n]]..t\n",
+		},
+		{
+			name:     "quoted strings over several lines",
+			input:    "s = \"first\n   second #{a  +  b}\"  ..  t",
+			expected: "s=\"first\n   second #{a  +  b}\"..t\n",
+		},
+		{
+			name:     "indentation of tabs mixed with spaces",
+			input:    "if a\n\tb = 1\n\tif c\n\t  d = 2\n\te = 3\nf = 4",
+			expected: "if a\n b=1\n if c\n  d=2\n e=3\nf=4\n",
+		},
+		{
+			name:     "blank lines and comments are dropped",
+			input:    "a = 1\n\n   \n-- only a comment\n  -- indented comment\nb = 2",
+			expected: "a=1\nb=2\n",
+		},
+		{
+			name:     "keywords before an expression",
+			input:    "return -x\nif not (a) then b",
+			expected: "return-x\nif not(a)then b\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			minified, err := MoonscriptLanguageService{}.Minify(test.input)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if minified != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, minified)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/literal"
 )
 
 // MoonscriptLanguageService is a container struct that encapsulates a bunch of methods that
@@ -14,9 +15,6 @@ import (
 type MoonscriptLanguageService struct {
 }
 
-// detects if a line of code contains a single line comment
-var reSingleLineComment = regexp.MustCompile(`--.*`)
-
 // knowing that a line contains an import statement, extracts a string containing comma-separated import
 // symbols, as well as the relative import path to the file these symbols reside
 var reImportExtract = regexp.MustCompile(`import\s+(.+)\s+from\s+require\s+"([\/\w]+)"`)
@@ -32,9 +30,15 @@ var reExtractExportedSymbols = regexp.MustCompile(`^(\w+)\s*=`)
 // extracts an exported symbol of kind class [identifier]
 var reExtractExportedClass = regexp.MustCompile(`^class\s+(\w+)`)
 
+// matches the lines that continue the top level statement before them, like indented lines and closing brackets
+var reContinuesDeclaration = regexp.MustCompile(`^(?:\s|[)\]}])`)
+
 // determines if the given line matches the structure needed to be a prelude comment
 var reIsPreludeComment = regexp.MustCompile(`^--\s*\w+\s*:`)
 
+// detects a macro, which is --# followed by its name, so that a line like --#------------ stays a comment
+var reIsMacro = regexp.MustCompile(`^\s*--#\s*\w`)
+
 var reGetMacroType = regexp.MustCompile(`--#\s*(\w+)`)
 var reGetMacroArgs = regexp.MustCompile(`--#\s*\w+\s+(.*)$`)
 var reBreakDownMacroArgs = regexp.MustCompile(`\S+`)
@@ -45,11 +49,70 @@ var reGetMacroStringDeclarationArgs = regexp.MustCompile(`--#\s*\w+\s+(\w+)\s+(.
 
 var reIdentifiers = regexp.MustCompile(`\w+`)
 
+// blobDecoder is the function that decodes the strings made by blob.Pack, which is written into the code once
+const blobDecoder = `export ticc_unblob = (s) ->
+  d, o, a, b, i = {}, {}, 0, 0, 1
+  for k = 1, #s
+    a = a * 64 + s\byte(k) - 48
+    b += 6
+    if b >= 8
+      b -= 8
+      p = 2 ^ b
+      v = math.floor(a / p)
+      d[#d + 1] = v
+      a -= v * p
+  while i <= #d
+    c = d[i]
+    i += 1
+    if c < 128
+      for k = 0, c
+        o[#o + 1] = d[i]
+        i += 1
+    else
+      for k = 1, c - 125
+        o[#o + 1] = d[i]
+      i += 1
+  o`
+
+// literalStyle describes how values are written as moonscript table literals
+var literalStyle = literal.Style{
+	Nil:       "nil",
+	True:      "true",
+	False:     "false",
+	ListOpen:  "{",
+	ListClose: "}",
+	MapOpen:   "{",
+	MapClose:  "}",
+	Separator: ", ",
+	Key: func(key string) string {
+		if literal.IsIdentifier(key) && !keywords[key] {
+			return key + ": "
+		}
+		return quoteString(key) + ": "
+	},
+	Quote: quoteString,
+}
+
+// keywords cannot be used as bare keys in a table literal
+var keywords = map[string]bool{
+	"and": true, "break": true, "class": true, "continue": true, "do": true, "else": true, "elseif": true,
+	"export": true, "extends": true, "false": true, "for": true, "from": true, "if": true, "import": true,
+	"in": true, "local": true, "nil": true, "not": true, "or": true, "return": true, "super": true,
+	"switch": true, "then": true, "true": true, "unless": true, "using": true, "when": true, "while": true,
+	"with": true,
+}
+
+// quoteString uses single quotes, since double quoted strings in moonscript interpolate anything inside #{}
+func quoteString(s string) string {
+	return literal.Escape(s, '\'', "")
+}
+
 // StripUnimportant returns a new line which is the result of stripping all the unimportant or non-usable
-// characters from it. This includes stripping away unneeded whitespace, comments, and any text that comes after comments
+// characters from it. This includes stripping away unneeded whitespace, comments, and any text that comes after comments.
+// The compiler uses StripBlocks instead, which knows about the strings that span several lines.
 func (ls MoonscriptLanguageService) StripUnimportant(line string) string {
-	withoutComments := reSingleLineComment.ReplaceAllString(line, "")
-	return strings.TrimRight(withoutComments, " \t\n\r")
+	stripped, _ := ls.StripBlocks(line, nil)
+	return stripped
 }
 
 // IsLineImport Determines whether a line of code contains an import statement. In moonscript, this is the 'require' token.
@@ -129,6 +192,12 @@ func (ls MoonscriptLanguageService) GetExportDeclarations(line string) []string
 	return []string{}
 }
 
+// ContinuesDeclaration determines if a line continues the top level declaration on the lines before it, so that the
+// declaration can be left out as a whole when it is not used
+func (ls MoonscriptLanguageService) ContinuesDeclaration(statement []string, line string) bool {
+	return reContinuesDeclaration.MatchString(line)
+}
+
 // ExtractPrelude extracts a string from the supplied main file code. This string is the prelude-
 // a set of comments that must appear at the top of a file used by the TIC-80 to determine the title,
 // author, description, language, and input type of the game.
@@ -149,6 +218,28 @@ func (ls MoonscriptLanguageService) ExtractPrelude(mainFileCode string) string {
 	return result
 }
 
+// FormatLiteral writes a value built by the literal package as a moonscript table, string, or other literal
+func (ls MoonscriptLanguageService) FormatLiteral(value interface{}) string {
+	return literal.Format(value, literalStyle)
+}
+
+// FormatDeclaration writes a top-level assignment that makes the value available to the whole program
+func (ls MoonscriptLanguageService) FormatDeclaration(name string, value string) string {
+	return name + " = " + value
+}
+
+// BlobDecoder writes the function that turns the strings made by blob.Pack back into bytes, as an exported moonscript
+// function. The bytes come back as a table indexed from 1. Moonscript has no bitwise operators, so the bits are
+// taken apart with arithmetic instead.
+func (ls MoonscriptLanguageService) BlobDecoder() []string {
+	return strings.Split(blobDecoder, "\n")
+}
+
+// FormatBlobDecode writes a call to the function from BlobDecoder
+func (ls MoonscriptLanguageService) FormatBlobDecode(packed string) string {
+	return "ticc_unblob(" + ls.FormatLiteral(packed) + ")"
+}
+
 // SubstituteDefines takes in a line of code and the current set of previously-declared defines. It then
 // detects any occurences of the defines that should be replaced and returns a string with these occurences
 // replaced by their correct definitions.
@@ -164,8 +255,7 @@ func (ls MoonscriptLanguageService) SubstituteDefines(line string, defines map[s
 
 // IsLineMacro determines if the given line constitutes a macro declaration of some sort
 func (ls MoonscriptLanguageService) IsLineMacro(line string) bool {
-	matches, _ := regexp.MatchString(`^\s*--#`, line)
-	return matches
+	return reIsMacro.MatchString(line)
 }
 
 // GetMacroType will determine what type of macro a given line is, provided that it has been
@@ -173,6 +263,10 @@ func (ls MoonscriptLanguageService) IsLineMacro(line string) bool {
 func (ls MoonscriptLanguageService) GetMacroType(line string) compiler.MacroType {
 	matchInfo := reGetMacroType.FindStringSubmatch(line)
 
+	if len(matchInfo) < 2 {
+		return compiler.MacroTypeUnknown
+	}
+
 	symbol := matchInfo[1]
 
 	switch strings.ToUpper(symbol) {
@@ -180,6 +274,12 @@ func (ls MoonscriptLanguageService) GetMacroType(line string) compiler.MacroType
 		return compiler.MacroTypeDefine
 	case "STRING":
 		return compiler.MacroTypeString
+	case "DATA":
+		return compiler.MacroTypeData
+	case "EMBED":
+		return compiler.MacroTypeEmbed
+	case "BLOB":
+		return compiler.MacroTypeBlob
 	case "IF":
 		return compiler.MacroTypeIf
 	case "ELSEIF":
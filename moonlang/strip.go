@@ -0,0 +1,36 @@
+package moonlang
+
+import (
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+// StripBlocks strips the comments and the trailing whitespace from a line like StripUnimportant, keeping track of the
+// strings that span several lines. Strings are skipped, so a -- inside of one is not mistaken for a comment.
+func (ls MoonscriptLanguageService) StripBlocks(line string, open *compiler.OpenBlock) (string, *compiler.OpenBlock) {
+	i := 0
+
+	if open != nil {
+		end, closed := _scanStringBody(line, 0, open.Closing)
+		if !closed {
+			// the string is kept exactly as it is, including the whitespace at the end of the line
+			return line, open
+		}
+		i = end
+	}
+
+	for i < len(line) {
+		if strings.HasPrefix(line[i:], "--") {
+			return strings.TrimRight(line[:i], " \t\n\r"), nil
+		}
+
+		token, closing := _nextToken(line, i)
+		if closing != "" {
+			return line, &compiler.OpenBlock{Closing: closing}
+		}
+		i += len(token)
+	}
+
+	return strings.TrimRight(line, " \t\n\r"), nil
+}
@@ -0,0 +1,56 @@
+package moonlang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func TestStripBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{
+			name:     "line comments",
+			lines:    []string{"x = 1 -- one", "-- only a comment", "  y = 2  "},
+			expected: []string{"x = 1", "", "  y = 2"},
+		},
+		{
+			name:     "dashes inside strings",
+			lines:    []string{`s = "a -- b" -- comment`, `t = 'c -- d', "#{'--'} e"`},
+			expected: []string{`s = "a -- b"`, `t = 'c -- d', "#{'--'} e"`},
+		},
+		{
+			name:     "long strings",
+			lines:    []string{"s = [==[  ", "-- not a comment ]]  ", "", "]==] .. t -- comment"},
+			expected: []string{"s = [==[  ", "-- not a comment ]]  ", "", "]==] .. t"},
+		},
+		{
+			name:     "quoted strings over several lines",
+			lines:    []string{`s = "first`, `  second \" -- still`, `  third" -- comment`},
+			expected: []string{`s = "first`, `  second \" -- still`, `  third"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ls := MoonscriptLanguageService{}
+			var open *compiler.OpenBlock
+
+			for i, line := range test.lines {
+				var stripped string
+				stripped, open = ls.StripBlocks(line, open)
+
+				if stripped != test.expected[i] {
+					t.Errorf("line %d: expected %q, got %q", i+1, test.expected[i], stripped)
+				}
+			}
+
+			if open != nil {
+				t.Errorf("expected everything to be closed, but %q is still open", open.Closing)
+			}
+		})
+	}
+}
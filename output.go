@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/novemberisms/ticc/assets"
+	"github.com/novemberisms/ticc/cart"
+)
+
+// loadOutputCart reads the cartridge that the compiled code will be written into, so that the sprites, map, sounds and
+// everything else already in it are kept. A new cartridge is made if the output file does not exist yet, or if the
+// output is plain code without any data.
+func loadOutputCart() (*cart.Cart, error) {
+	switch {
+	case Args.outputMode == outputCart || Args.outputMode == outputPNG:
+		if _, err := os.Stat(Args.outputFile); err != nil {
+			return cart.New(), nil
+		}
+
+		tic, err := cart.Load(Args.outputFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading cartridge '%s':\n%w", Args.outputFile, err)
+		}
+		return tic, nil
+	case Args.withData:
+		// data edited in the TIC-80 itself and saved into the text file survives a recompile
+		existing, err := ioutil.ReadFile(Args.outputFile)
+		if err != nil {
+			return cart.New(), nil
+		}
+
+		tic, err := cart.ParseText(string(existing))
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the data sections of '%s':\n%w", Args.outputFile, err)
+		}
+		return tic, nil
+	default:
+		return cart.New(), nil
+	}
+}
+
+// outputHasData determines if the output holds the data of a cartridge (sprites, map, sounds...) along with the code.
+// The assets of the project are only imported if it does.
+func outputHasData() bool {
+	return Args.outputMode != outputText || Args.withData
+}
+
+// writeOutput writes the compiled code to the output file in the format given by Args.outputMode. Unless the output
+// is plain code, the code replaces the code of the given cartridge, which is written along with it.
+func writeOutput(tic *cart.Cart, code string) error {
+	if !outputHasData() {
+		return ioutil.WriteFile(Args.outputFile, []byte(code), 0644)
+	}
+
+	if err := tic.SetCode(code); err != nil {
+		return err
+	}
+
+	switch Args.outputMode {
+	case outputCart:
+		return tic.Save(Args.outputFile)
+	case outputPNG:
+		var screen []byte
+		if chunk := tic.Chunk(cart.ChunkScreen, 0); chunk != nil {
+			screen = chunk.Data
+		}
+		return tic.SavePNG(Args.outputFile, assets.CoverImage(screen, assets.CartPalette(tic)))
+	}
+
+	text, err := tic.Text(Args.language.commentPrefix())
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(Args.outputFile, []byte(text), 0644)
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/novemberisms/ticc/assets"
+	"github.com/novemberisms/ticc/cart"
+	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/literal"
+)
+
+// assetExtensions are the file extensions of the files in the project directory that hold data for the cartridge
+var assetExtensions = []string{
+	".ticdata",
+	".ticchunk",
+	".png",
+	".tmj",
+	".tmx",
+	".gif",
+	".gpl",
+	".hex",
+	".txt",
+	".wav",
+	".bdf",
+}
+
+// spriteSheets maps the names of the sprite sheet images that can be in the project directory to the chunks they fill
+var spriteSheets = []struct {
+	filename  string
+	chunkType cart.ChunkType
+	// the number spr() uses for the first tile of the sheet
+	firstSprite int
+}{
+	{"tiles.png", cart.ChunkTiles, 0},
+	{"sprites.png", cart.ChunkSprites, 256},
+}
+
+// spriteDirectory is the directory of individual sprite images in the project directory
+const spriteDirectory = "sprites"
+
+// mapFiles are the names the Tiled map can have in the project directory, in order of preference
+var mapFiles = []string{
+	"map.tmj",
+	"map.tmx",
+}
+
+// paletteFiles are the names the palette can have in the project directory, in order of preference
+var paletteFiles = []string{
+	"palette.gpl",
+	"palette.hex",
+	"palette.txt",
+}
+
+// coverFiles are the names the cover image can have in the project directory, in order of preference
+var coverFiles = []string{
+	"cover.png",
+	"cover.gif",
+}
+
+// waveformTableFile is the name of the text table of waveforms in the project directory
+const waveformTableFile = "waveforms.txt"
+
+// sfxFile is the name of the text file of sound effects in the project directory
+const sfxFile = "sfx.txt"
+
+// fontFile is the name of the bitmap font in the project directory
+const fontFile = "font.bdf"
+
+// fontWidthsName is the name of the table generated from the bitmap font, holding the width of every character
+const fontWidthsName = "FONT_WIDTHS"
+
+// musicFile is the name of the text file of tracks and patterns in the project directory
+const musicFile = "music.txt"
+
+// matches the name of a WAV file holding a single waveform, like 'wave3.wav'
+var reWaveformFile = regexp.MustCompile(`^wave(\d+)\.wav$`)
+
+// matches the runs of characters in the name of a color that cannot be part of an identifier
+var reNonIdentifier = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// matches the name of a file holding the raw data of a chunk, like 'samples.ticchunk' or 'map1.ticchunk'
+var reRawChunkFile = regexp.MustCompile(`^([a-z_]+?)(\d?)\.ticchunk$`)
+
+// importAssets reads the asset files in the project directory and writes their data into the cartridge,
+// replacing whatever data the cartridge had for them. Any code generated from the assets is added to the compiler.
+func importAssets(tic *cart.Cart, comp *compiler.Compiler) error {
+	if err := _importRawChunks(tic); err != nil {
+		return err
+	}
+
+	dataFiles, err := filepath.Glob(path.Join(Args.directory.Name(), "*.ticdata"))
+
+	if err != nil {
+		return err
+	}
+
+	// files are imported in alphabetical order, so later files take precedence over earlier ones
+	for _, dataFile := range dataFiles {
+		text, err := ioutil.ReadFile(dataFile)
+		if err != nil {
+			return err
+		}
+
+		data, err := cart.ParseText(string(text))
+		if err != nil {
+			return fmt.Errorf("Error reading data file '%s':\n%w", dataFile, err)
+		}
+
+		for _, chunk := range data.SectionChunks() {
+			tic.SetChunk(chunk.Type, chunk.Bank, chunk.Data)
+		}
+	}
+
+	if err := _importPalette(tic, comp); err != nil {
+		return err
+	}
+
+	// the images have to be matched against the palette, so they go after anything that could change it
+	palette := assets.CartPalette(tic)
+
+	// the sprites that the sprite sheets and the font have drawn something into, which the sprite directory keeps clear of
+	reserved := make([]bool, assets.SpriteCount)
+
+	for _, sheet := range spriteSheets {
+		sheetFile := path.Join(Args.directory.Name(), sheet.filename)
+
+		if !fileExists(sheetFile) {
+			continue
+		}
+
+		data, err := assets.ImportSpriteSheet(sheetFile, palette)
+		if err != nil {
+			return fmt.Errorf("Error importing sprite sheet '%s':\n%w", sheetFile, err)
+		}
+
+		tic.SetChunk(sheet.chunkType, 0, cart.TrimTrailingZeros(data))
+
+		for tile := 0; tile < assets.SheetTiles*assets.SheetTiles; tile++ {
+			reserved[sheet.firstSprite+tile] = !assets.TileIsEmpty(data, tile)
+		}
+	}
+
+	// the font is drawn over the sprite sheets
+	if err := _importFont(tic, comp, reserved); err != nil {
+		return err
+	}
+
+	if err := _importSpriteDirectory(tic, comp, reserved, palette); err != nil {
+		return err
+	}
+
+	if coverFile := _findProjectFile(coverFiles); coverFile != "" {
+		data, err := assets.ImportCover(coverFile, palette)
+		if err != nil {
+			return fmt.Errorf("Error importing cover '%s':\n%w", coverFile, err)
+		}
+
+		tic.SetChunk(cart.ChunkScreen, 0, cart.TrimTrailingZeros(data))
+	}
+
+	if err := _importSound(tic, comp); err != nil {
+		return err
+	}
+
+	if err := _importMusic(tic, comp); err != nil {
+		return err
+	}
+
+	return _importMap(tic, comp)
+}
+
+// _importSound writes the waveforms of waveforms.txt and of every waveN.wav into the WAVEFORM chunk, and the sound
+// effects of sfx.txt into the SAMPLES chunk. Sound effects with a name have their index defined under it, like
+// SFX_JUMP for a sound effect called 'jump'.
+func _importSound(tic *cart.Cart, comp *compiler.Compiler) error {
+	waveforms := make(map[int][]byte)
+
+	if tableFile := _findProjectFile([]string{waveformTableFile}); tableFile != "" {
+		table, err := assets.ImportWaveformTable(tableFile)
+		if err != nil {
+			return fmt.Errorf("Error importing waveforms '%s':\n%w", tableFile, err)
+		}
+		waveforms = table
+	}
+
+	wavFiles, err := filepath.Glob(path.Join(Args.directory.Name(), "wave*.wav"))
+
+	if err != nil {
+		return err
+	}
+
+	for _, wavFile := range wavFiles {
+		matchInfo := reWaveformFile.FindStringSubmatch(filepath.Base(wavFile))
+		index := -1
+		if matchInfo != nil {
+			index, _ = strconv.Atoi(matchInfo[1])
+		}
+
+		if index < 0 || index >= assets.WaveformCount {
+			return fmt.Errorf("Error importing waveform '%s':\nthe name must be 'wave' followed by a waveform from 0 to %d, like 'wave3.wav'", wavFile, assets.WaveformCount-1)
+		}
+
+		if _, duplicate := waveforms[index]; duplicate {
+			return fmt.Errorf("Error importing waveform '%s':\nwaveform %d is already defined in %s", wavFile, index, waveformTableFile)
+		}
+
+		samples, err := assets.ImportWaveformWAV(wavFile)
+		if err != nil {
+			return fmt.Errorf("Error importing waveform '%s':\n%w", wavFile, err)
+		}
+
+		waveforms[index] = samples
+	}
+
+	if len(waveforms) > 0 {
+		assets.SetCartWaveforms(tic, waveforms)
+	}
+
+	effectsFile := _findProjectFile([]string{sfxFile})
+
+	if effectsFile == "" {
+		return nil
+	}
+
+	effects, err := assets.ImportSFX(effectsFile)
+
+	if err != nil {
+		return fmt.Errorf("Error importing sound effects '%s':\n%w", effectsFile, err)
+	}
+
+	assets.SetCartSFX(tic, effects)
+
+	for _, effect := range effects {
+		name := strings.Trim(reNonIdentifier.ReplaceAllString(effect.Name, "_"), "_")
+		if name != "" {
+			comp.Define("SFX_"+strings.ToUpper(name), strconv.Itoa(effect.Index))
+		}
+	}
+
+	return nil
+}
+
+// _importPalette writes the palette file into the PALETTE chunk, and defines the index of every color under its name
+// (like COLOR_RED for a color called 'red'). Colors without a name are defined by their index, like COLOR_3.
+func _importPalette(tic *cart.Cart, comp *compiler.Compiler) error {
+	paletteFile := _findProjectFile(paletteFiles)
+
+	if paletteFile == "" {
+		return nil
+	}
+
+	palette, names, err := assets.ImportPalette(paletteFile, assets.CartPalette(tic))
+
+	if err != nil {
+		return fmt.Errorf("Error importing palette '%s':\n%w", paletteFile, err)
+	}
+
+	assets.SetCartPalette(tic, palette)
+
+	for i, name := range names {
+		name = strings.Trim(reNonIdentifier.ReplaceAllString(name, "_"), "_")
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		comp.Define("COLOR_"+strings.ToUpper(name), strconv.Itoa(i))
+	}
+
+	return nil
+}
+
+// _findProjectFile finds the first of the given files that is in the project directory, or returns an empty string
+// if none of them are
+func _findProjectFile(filenames []string) string {
+	for _, filename := range filenames {
+		if candidate := path.Join(Args.directory.Name(), filename); fileExists(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// _importRawChunks copies the raw chunk files in the project directory (as written by 'ticc extract') into the
+// cartridge byte for byte
+func _importRawChunks(tic *cart.Cart) error {
+	chunkFiles, err := filepath.Glob(path.Join(Args.directory.Name(), "*.ticchunk"))
+
+	if err != nil {
+		return err
+	}
+
+	for _, chunkFile := range chunkFiles {
+		matchInfo := reRawChunkFile.FindStringSubmatch(filepath.Base(chunkFile))
+
+		var chunkType cart.ChunkType
+		known := false
+
+		if matchInfo != nil {
+			chunkType, known = cart.ChunkTypeByName(matchInfo[1])
+		}
+
+		if !known {
+			return fmt.Errorf("Error reading chunk file '%s':\nthe name must be a chunk type followed by an optional bank, like 'samples.ticchunk' or 'map1.ticchunk'", chunkFile)
+		}
+
+		bank, _ := strconv.Atoi(matchInfo[2])
+
+		data, err := ioutil.ReadFile(chunkFile)
+		if err != nil {
+			return err
+		}
+
+		tic.SetChunk(chunkType, bank, data)
+	}
+
+	return nil
+}
+
+// rawChunkFileName gives the name of the file that holds the raw data of a chunk, the reverse of reRawChunkFile
+func rawChunkFileName(chunkType cart.ChunkType, bank int) string {
+	name := strings.ToLower(chunkType.String())
+	if bank > 0 {
+		name += strconv.Itoa(bank)
+	}
+	return name + ".ticchunk"
+}
+
+// _importMap writes the first tile layer of the Tiled map into the MAP chunk, and generates the table of entities
+// from its object layers if it was asked for with -entities
+func _importMap(tic *cart.Cart, comp *compiler.Compiler) error {
+	mapFile := _findProjectFile(mapFiles)
+
+	if mapFile == "" {
+		if Args.entities != "" {
+			return errors.New("-entities was given, but there is no map.tmj or map.tmx in the project directory")
+		}
+		return nil
+	}
+
+	tiledMap, err := assets.ImportTiledMap(mapFile)
+
+	if err != nil {
+		return fmt.Errorf("Error importing map '%s':\n%w", mapFile, err)
+	}
+
+	tic.SetChunk(cart.ChunkMap, 0, cart.TrimTrailingZeros(tiledMap.Tiles))
+
+	if Args.entities != "" {
+		entities := comp.FormatLiteral(tiledMap.Entities)
+		comp.AddGeneratedCode(comp.FormatDeclaration(Args.entities, entities))
+	}
+
+	return nil
+}
+
+// _importFont draws the characters of font.bdf in the range given by -font-range into the sprites, and generates a
+// table of the width of every character (keyed by the character itself) so that text can be laid out without
+// hard-coding the metrics of the font. The sprites of the characters are marked as reserved.
+func _importFont(tic *cart.Cart, comp *compiler.Compiler, reserved []bool) error {
+	bdfFile := _findProjectFile([]string{fontFile})
+
+	if bdfFile == "" {
+		return nil
+	}
+
+	glyphs, err := assets.ImportBDF(bdfFile, Args.fontFirst, Args.fontLast)
+
+	if err != nil {
+		return fmt.Errorf("Error importing font '%s':\n%w", bdfFile, err)
+	}
+
+	if err := assets.SetCartGlyphs(tic, glyphs, Args.fontBase, byte(Args.fontColor)); err != nil {
+		return fmt.Errorf("Error importing font '%s':\n%w", bdfFile, err)
+	}
+
+	widths := &literal.Map{}
+	for _, glyph := range glyphs {
+		widths.Set(string(rune(glyph.Code)), glyph.Width)
+		reserved[Args.fontBase+glyph.Code] = true
+	}
+
+	comp.AddGeneratedCode(comp.FormatDeclaration(fontWidthsName, comp.FormatLiteral(widths)))
+
+	return nil
+}
+
+// _importSpriteDirectory packs the PNG images in the sprites directory into the sprite bank, in alphabetical order,
+// and defines the number of every sprite under the name of its file (like SPR_PLAYER_IDLE for
+// 'sprites/player_idle.png') so that code never has to refer to sprites by their number
+func _importSpriteDirectory(tic *cart.Cart, comp *compiler.Compiler, reserved []bool, palette assets.Palette) error {
+	spriteFiles, err := filepath.Glob(path.Join(Args.directory.Name(), spriteDirectory, "*.png"))
+
+	if err != nil || len(spriteFiles) == 0 {
+		return err
+	}
+
+	packed, err := assets.PackSprites(tic, spriteFiles, reserved, palette)
+
+	if err != nil {
+		return fmt.Errorf("Error importing sprites from '%s':\n%w", spriteDirectory, err)
+	}
+
+	for _, sprite := range packed {
+		name := strings.TrimSuffix(filepath.Base(sprite.Filename), filepath.Ext(sprite.Filename))
+		name = strings.Trim(reNonIdentifier.ReplaceAllString(name, "_"), "_")
+		comp.Define("SPR_"+strings.ToUpper(name), strconv.Itoa(sprite.Index))
+	}
+
+	return nil
+}
+
+// _importMusic writes the tracks and patterns of music.txt into the MUSIC and PATTERNS chunks. Tracks with a name have
+// their index defined under it, like MUSIC_THEME for a track called 'theme'.
+func _importMusic(tic *cart.Cart, comp *compiler.Compiler) error {
+	trackerFile := _findProjectFile([]string{musicFile})
+
+	if trackerFile == "" {
+		return nil
+	}
+
+	music, err := assets.ImportMusic(trackerFile)
+
+	if err != nil {
+		return fmt.Errorf("Error importing music '%s':\n%w", trackerFile, err)
+	}
+
+	assets.SetCartMusic(tic, music)
+
+	for index, name := range music.TrackNames {
+		name = strings.Trim(reNonIdentifier.ReplaceAllString(name, "_"), "_")
+		if name != "" {
+			comp.Define("MUSIC_"+strings.ToUpper(name), strconv.Itoa(index))
+		}
+	}
+
+	return nil
+}
+
+// isAssetFile determines if the given file name is one of the asset files that go into the cartridge
+func isAssetFile(name string) bool {
+	ext := filepath.Ext(name)
+	for _, assetExt := range assetExtensions {
+		if ext == assetExt {
+			return true
+		}
+	}
+	return false
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
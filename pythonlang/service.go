@@ -0,0 +1,407 @@
+package pythonlang
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/literal"
+)
+
+// PythonLanguageService is a container struct that encapsulates a bunch of methods that
+// take in a line of code and do text processing to see if the line matches certain properties
+// based on the language this service provides.
+type PythonLanguageService struct {
+}
+
+// matches `from <module> import <symbols>`, where the symbols may be wrapped in parentheses
+var reFromImport = regexp.MustCompile(`^\s*from\s+([\w\.]+)\s+import\s+\(?([^)]*)\)?`)
+
+// matches a bare `import <module>`
+var reBareImport = regexp.MustCompile(`^\s*import\s+([\w\.]+)\s*$`)
+
+// finds the module being imported on a line, whichever kind of import it is
+var reImportedModule = regexp.MustCompile(`^\s*(?:from|import)\s+([\w\.]+)`)
+
+// given a comma-separated string of imported names, extracts each name. Renamed imports (`A as B`) are not
+// supported since the files are stitched into the same scope, so only the first word of each import is relevant
+var reExtractImportSymbol = regexp.MustCompile(`\b\w+\b`)
+
+// extracts an exported symbol of kind def [identifier] or class [identifier]
+var reExportDefOrClass = regexp.MustCompile(`^(?:async\s+)?(?:def|class)\s+(\w+)`)
+
+// extracts the exported symbols of kind [identifier], [identifier] = ...
+var reExportAssignment = regexp.MustCompile(`^(\w+(?:\s*,\s*\w+)*)\s*(?::[^=]+)?=[^=]`)
+
+// given a comma-separated list of identifiers, finds each identifier within
+var reExtractSymbols = regexp.MustCompile(`\b\w+\b`)
+
+// matches the lines that continue the top level statement before them, like indented lines and the 'else' of an 'if'
+var reContinuesDeclaration = regexp.MustCompile(`^(?:\s|(?:elif|else|except|finally)\b|[)\]}])`)
+
+// determines if the given line matches the structure needed to be a prelude comment
+var reIsPreludeComment = regexp.MustCompile(`^#\s*\w+\s*:`)
+
+// detects a macro, which is ## followed right away by its name, like ##define. Any other line starting with ## is a
+// comment, like the '## Helpers' headings that are common in python code.
+var reIsMacro = regexp.MustCompile(`^\s*##(?i:define|string|data|embed|blob|if|elseif|else|endif)\b`)
+
+var reGetMacroType = regexp.MustCompile(`##\s*(\w+)`)
+var reGetMacroArgs = regexp.MustCompile(`##\s*\w+\s+(.*)$`)
+var reBreakDownMacroArgs = regexp.MustCompile(`\S+`)
+
+// the first \w+ is the ## STRING text. The first capturing group gets the string name,
+// and the second capturing group gets the string contents
+var reGetMacroStringDeclarationArgs = regexp.MustCompile(`##\s*\w+\s+(\w+)\s+(.*)$`)
+
+var reIdentifiers = regexp.MustCompile(`\w+`)
+
+// blobDecoder is the function that decodes the strings made by blob.Pack, which is written into the code once
+const blobDecoder = `def ticc_unblob(s):
+  d = []
+  o = []
+  a = 0
+  b = 0
+  for ch in s:
+    a = (a << 6) | (ord(ch) - 48)
+    b += 6
+    if b >= 8:
+      b -= 8
+      d.append(a >> b)
+      a &= (1 << b) - 1
+  i = 0
+  while i < len(d):
+    c = d[i]
+    i += 1
+    if c < 128:
+      for k in range(c + 1):
+        o.append(d[i])
+        i += 1
+    else:
+      for k in range(c - 125):
+        o.append(d[i])
+      i += 1
+  return o`
+
+// literalStyle describes how values are written as python lists and dicts
+var literalStyle = literal.Style{
+	Nil:       "None",
+	True:      "True",
+	False:     "False",
+	ListOpen:  "[",
+	ListClose: "]",
+	MapOpen:   "{",
+	MapClose:  "}",
+	Separator: ", ",
+	Key: func(key string) string {
+		return literal.Escape(key, '"', "") + ": "
+	},
+	Quote: func(s string) string {
+		return literal.Escape(s, '"', "")
+	},
+}
+
+// builtinModules are the modules that ship with the python runtime of the TIC-80. Imports of these are left
+// in the code as they are instead of being stitched in from the project directory.
+var builtinModules = map[string]bool{
+	"array2d":     true,
+	"base64":      true,
+	"bisect":      true,
+	"collections": true,
+	"colorsys":    true,
+	"dataclasses": true,
+	"datetime":    true,
+	"easing":      true,
+	"enum":        true,
+	"functools":   true,
+	"heapq":       true,
+	"itertools":   true,
+	"json":        true,
+	"linalg":      true,
+	"math":        true,
+	"operator":    true,
+	"os":          true,
+	"pickle":      true,
+	"random":      true,
+	"re":          true,
+	"string":      true,
+	"sys":         true,
+	"time":        true,
+	"traceback":   true,
+	"typing":      true,
+}
+
+// StripUnimportant returns a new line which is the result of stripping all the unimportant or non-usable
+// characters from it. This includes stripping away unneeded whitespace, comments, and any text that comes after comments.
+// The compiler uses StripBlocks instead, which knows about the strings that span several lines.
+func (ls PythonLanguageService) StripUnimportant(line string) string {
+	stripped, _ := ls.StripBlocks(line, nil)
+	return stripped
+}
+
+// IsLineImport determines whether a line of code contains an import statement. In python, this is either
+// 'from <module> import <symbols>' or 'import <module>', as long as the module is not one of the builtin ones.
+func (ls PythonLanguageService) IsLineImport(line string) bool {
+	matchInfo := reImportedModule.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return false
+	}
+
+	topLevelModule := strings.Split(matchInfo[1], ".")[0]
+
+	return !builtinModules[topLevelModule]
+}
+
+// GetImportData will extract a slice of imported symbols and the relative path to the file that is being imported given
+// a line of code with an import statement
+func (ls PythonLanguageService) GetImportData(line string) (compiler.ImportData, error) {
+	matchInfo := reFromImport.FindStringSubmatch(line)
+
+	if len(matchInfo) != 3 {
+		// this means the line does not match the template
+		// from <module> import <symbols>
+
+		// check if the line is a bare import of a whole module
+		if bareImport := reBareImport.FindStringSubmatch(line); len(bareImport) > 0 {
+			return compiler.ImportData{
+				Path: modulePathToFile(bareImport[1]),
+			}, nil
+		}
+
+		return compiler.ImportData{}, errors.New(`import line does not match the templates: 'from {module} import {symbols}' or 'import {module}'`)
+	}
+
+	symbols := []string{}
+	for _, imported := range strings.Split(matchInfo[2], ",") {
+		// a star import brings in everything, so there is nothing in particular to validate
+		if symbol := reExtractImportSymbol.FindString(imported); symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return compiler.ImportData{
+		Symbols: symbols,
+		Path:    modulePathToFile(matchInfo[1]),
+	}, nil
+}
+
+// modulePathToFile converts a python module path like "entities.player" into the relative path of its file
+func modulePathToFile(modulePath string) string {
+	return strings.ReplaceAll(modulePath, ".", "/") + ".py"
+}
+
+// IsExportDeclaration determines if a line contains a top-level declaration that should be available to other
+// files importing this one
+func (ls PythonLanguageService) IsExportDeclaration(line string) bool {
+	// in python, the following are export declarations
+	// * def update():
+	// * class Entity:
+	// * SPEED = 2
+	// and they must all have zero leading indentation
+
+	if reExportDefOrClass.MatchString(line) {
+		return true
+	}
+
+	if reExportAssignment.MatchString(line) {
+		return true
+	}
+
+	return false
+}
+
+// GetExportDeclarations extracts a list of exported symbols from the line
+func (ls PythonLanguageService) GetExportDeclarations(line string) []string {
+	matchInfo := reExportDefOrClass.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return matchInfo[1:2]
+	}
+
+	matchInfo = reExportAssignment.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return reExtractSymbols.FindAllString(matchInfo[1], -1)
+	}
+
+	return []string{}
+}
+
+// ContinuesDeclaration determines if a line continues the top level statement made of the lines before it, so that
+// the declaration can be left out as a whole when it is not used. Besides indented lines, a statement goes on while it
+// has a bracket or a string that is not closed yet, after a line ending in a backslash, and after a decorator, which
+// keeps the decorated definition from being left out on its own.
+func (ls PythonLanguageService) ContinuesDeclaration(statement []string, line string) bool {
+	if reContinuesDeclaration.MatchString(line) {
+		return true
+	}
+
+	last := statement[len(statement)-1]
+	if strings.HasPrefix(last, "@") || strings.HasSuffix(last, "\\") {
+		return true
+	}
+
+	depth, inString := openBrackets(strings.Join(statement, "\n"))
+	return depth > 0 || inString
+}
+
+// openBrackets counts the brackets that are not closed by the end of the code, and determines if it ends inside of a
+// string. Comments have already been stripped.
+func openBrackets(code string) (int, bool) {
+	depth := 0
+
+	for i := 0; i < len(code); i++ {
+		switch char := code[i]; char {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '"', '\'':
+			quote := string(char)
+			if strings.HasPrefix(code[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+
+			end := i + len(quote)
+			for ; end < len(code) && !strings.HasPrefix(code[end:], quote); end++ {
+				if code[end] == '\\' {
+					end++
+				} else if code[end] == '\n' && len(quote) == 1 {
+					// a single quoted string can't span lines, so it is left to python to complain about
+					break
+				}
+			}
+
+			if end >= len(code) {
+				return depth, true
+			}
+			i = end + len(quote) - 1
+		}
+	}
+
+	return depth, false
+}
+
+// ExtractPrelude extracts a string from the supplied main file code. This string is the prelude-
+// a set of comments that must appear at the top of a file used by the TIC-80 to determine the title,
+// author, description, language, and input type of the game.
+//
+// Normally, comments are stripped out by
+// StripUnimportant, which is why this needs to be its own separate method
+func (ls PythonLanguageService) ExtractPrelude(mainFileCode string) string {
+	result := ""
+	// split the code into lines
+	lines := strings.Split(mainFileCode, "\n")
+	for _, line := range lines {
+		if reIsPreludeComment.MatchString(line) {
+			result = result + line + "\n"
+		} else {
+			break
+		}
+	}
+	return result
+}
+
+// FormatLiteral writes a value built by the literal package as a python list, dict, string, or other literal
+func (ls PythonLanguageService) FormatLiteral(value interface{}) string {
+	return literal.Format(value, literalStyle)
+}
+
+// FormatDeclaration writes a top-level assignment that makes the value available to the whole program
+func (ls PythonLanguageService) FormatDeclaration(name string, value string) string {
+	return name + " = " + value
+}
+
+// BlobDecoder writes the function that turns the strings made by blob.Pack back into bytes, as a top-level python
+// function.
+func (ls PythonLanguageService) BlobDecoder() []string {
+	return strings.Split(blobDecoder, "\n")
+}
+
+// FormatBlobDecode writes a call to the function from BlobDecoder
+func (ls PythonLanguageService) FormatBlobDecode(packed string) string {
+	return "ticc_unblob(" + ls.FormatLiteral(packed) + ")"
+}
+
+// SubstituteDefines takes in a line of code and the current set of previously-declared defines. It then
+// detects any occurences of the defines that should be replaced and returns a string with these occurences
+// replaced by their correct definitions.
+func (ls PythonLanguageService) SubstituteDefines(line string, defines map[string]string) string {
+	return reIdentifiers.ReplaceAllStringFunc(line, func(identifier string) string {
+		replacement, isDefined := defines[identifier]
+		if !isDefined {
+			return identifier
+		}
+		return replacement
+	})
+}
+
+// IsLineMacro determines if the given line constitutes a macro declaration of some sort
+func (ls PythonLanguageService) IsLineMacro(line string) bool {
+	return reIsMacro.MatchString(line)
+}
+
+// GetMacroType will determine what type of macro a given line is, provided that it has been
+// detected previously by IsLineMacro.
+func (ls PythonLanguageService) GetMacroType(line string) compiler.MacroType {
+	matchInfo := reGetMacroType.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return compiler.MacroTypeUnknown
+	}
+
+	symbol := matchInfo[1]
+
+	switch strings.ToUpper(symbol) {
+	case "DEFINE":
+		return compiler.MacroTypeDefine
+	case "STRING":
+		return compiler.MacroTypeString
+	case "DATA":
+		return compiler.MacroTypeData
+	case "EMBED":
+		return compiler.MacroTypeEmbed
+	case "BLOB":
+		return compiler.MacroTypeBlob
+	case "IF":
+		return compiler.MacroTypeIf
+	case "ELSEIF":
+		return compiler.MacroTypeElseIf
+	case "ELSE":
+		return compiler.MacroTypeElse
+	case "ENDIF":
+		return compiler.MacroTypeEndIf
+	default:
+		return compiler.MacroTypeUnknown
+	}
+}
+
+// GetMacroArgs will return a slice of all the space-separated values that follow a
+// macro definition
+func (ls PythonLanguageService) GetMacroArgs(line string) []string {
+	matchInfo := reGetMacroArgs.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return []string{}
+	}
+
+	fullArgs := matchInfo[1]
+
+	return reBreakDownMacroArgs.FindAllString(fullArgs, -1)
+}
+
+// GetMacroStringDeclaration extracts the name and the contents of a string macro
+func (ls PythonLanguageService) GetMacroStringDeclaration(line string) (string, string, error) {
+	matchInfo := reGetMacroStringDeclarationArgs.FindStringSubmatch(line)
+
+	// the first string in matchInfo is always the full matched text
+	// the second string is the string name
+	// the third string is the string contents
+	if len(matchInfo) != 3 {
+		return "", "", errors.New("invalid format for string macro. must be ##string [STRING_NAME] STRING CONTENTS")
+	}
+
+	return matchInfo[1], matchInfo[2], nil
+}
@@ -0,0 +1,21 @@
+package pythonlang
+
+import (
+	"testing"
+)
+
+func TestIsLineMacro(t *testing.T) {
+	ls := PythonLanguageService{}
+
+	for _, line := range []string{"##define SPEED 2", "  ##if DEBUG", "##ENDIF", "##string TITLE Hello"} {
+		if !ls.IsLineMacro(line) {
+			t.Errorf("expected %q to be a macro", line)
+		}
+	}
+
+	for _, line := range []string{"## Helpers", "#####################", "##", "## define the player", "##defined"} {
+		if ls.IsLineMacro(line) {
+			t.Errorf("expected %q to be a comment", line)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package pythonlang
+
+import (
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+// StripBlocks strips the comments and the trailing whitespace from a line like StripUnimportant, keeping track of the
+// triple quoted strings that span several lines. A '#' inside of a string (like a hex color "#ff0000") does not start
+// a comment.
+func (ls PythonLanguageService) StripBlocks(line string, open *compiler.OpenBlock) (string, *compiler.OpenBlock) {
+	i := 0
+
+	if open != nil {
+		end := findClosingQuote(line, 0, open.Closing)
+		if end < 0 {
+			// the string is kept exactly as it is, including the whitespace at the end of the line
+			return line, open
+		}
+		i = end
+	}
+
+	for i < len(line) {
+		switch char := line[i]; char {
+		case '#':
+			return strings.TrimRight(line[:i], " \t\n\r"), nil
+		case '"', '\'':
+			quote := string(char)
+			if strings.HasPrefix(line[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+
+			end := findClosingQuote(line, i+len(quote), quote)
+			if end < 0 {
+				// a single quoted string only goes on to the next line after a backslash
+				if len(quote) == 3 || strings.HasSuffix(line, "\\") {
+					return line, &compiler.OpenBlock{Closing: quote}
+				}
+				return strings.TrimRight(line, " \t\n\r"), nil
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+
+	return strings.TrimRight(line, " \t\n\r"), nil
+}
+
+// findClosingQuote finds the position right after the quote that closes a string whose body starts at the given
+// position, or -1 if the string is not closed on this line. A backslash escapes the character after it.
+func findClosingQuote(line string, start int, quote string) int {
+	for i := start; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(line[i:], quote) {
+			return i + len(quote)
+		}
+	}
+	return -1
+}
@@ -0,0 +1,56 @@
+package pythonlang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func TestStripBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{
+			name:     "line comments",
+			lines:    []string{"x = 1 # one", "# only a comment", "  y = 2  "},
+			expected: []string{"x = 1", "", "  y = 2"},
+		},
+		{
+			name:     "hashes inside strings",
+			lines:    []string{`color = "#ff0000" # red`, `s = 'a # b', "\" # "`},
+			expected: []string{`color = "#ff0000"`, `s = 'a # b', "\" # "`},
+		},
+		{
+			name:     "triple quoted strings",
+			lines:    []string{`HELP = """Press Z  `, `# not a comment "quoted"  `, "", `to jump""" # comment`},
+			expected: []string{`HELP = """Press Z  `, `# not a comment "quoted"  `, "", `to jump"""`},
+		},
+		{
+			name:     "single quoted strings carried on by a backslash",
+			lines:    []string{`s = 'first \`, `second # still' # comment`},
+			expected: []string{`s = 'first \`, `second # still'`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ls := PythonLanguageService{}
+			var open *compiler.OpenBlock
+
+			for i, line := range test.lines {
+				var stripped string
+				stripped, open = ls.StripBlocks(line, open)
+
+				if stripped != test.expected[i] {
+					t.Errorf("line %d: expected %q, got %q", i+1, test.expected[i], stripped)
+				}
+			}
+
+			if open != nil {
+				t.Errorf("expected everything to be closed, but %q is still open", open.Closing)
+			}
+		})
+	}
+}
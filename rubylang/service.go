@@ -0,0 +1,356 @@
+package rubylang
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/literal"
+)
+
+// RubyLanguageService is a container struct that encapsulates a bunch of methods that
+// take in a line of code and do text processing to see if the line matches certain properties
+// based on the language this service provides.
+type RubyLanguageService struct {
+}
+
+// matches `require_relative "<path>"`, with or without parentheses and with either kind of quotes
+var reRequireRelative = regexp.MustCompile(`^\s*require_relative\s*\(?\s*["']([\w\/\.]+)["']\s*\)?`)
+
+// extracts an exported symbol of kind class [Constant] or module [Constant]
+var reExportClassOrModule = regexp.MustCompile(`^(?:class|module)\s+([A-Z]\w*)`)
+
+// extracts an exported symbol of kind def [identifier], including methods defined on self at the top level
+var reExportMethod = regexp.MustCompile(`^def\s+(?:self\.)?(\w+[?!]?)`)
+
+// extracts an exported symbol of kind [Constant] = ... or $[global] = ...
+var reExportAssignment = regexp.MustCompile(`^([A-Z]\w*|\$\w+)\s*=[^=~]`)
+
+// matches the lines that continue the top level statement before them, like indented lines and 'end'
+var reContinuesDeclaration = regexp.MustCompile(`^(?:\s|(?:end|else|elsif|when|rescue|ensure)\b|[)\]}])`)
+
+// determines if the given line matches the structure needed to be a prelude comment
+var reIsPreludeComment = regexp.MustCompile(`^#\s*\w+\s*:`)
+
+// detects a macro, which is ## followed right away by its name, like ##define. Any other line starting with ## is a
+// comment, like a '## Helpers' heading or a banner of #####.
+var reIsMacro = regexp.MustCompile(`^\s*##(?i:define|string|data|embed|blob|if|elseif|else|endif)\b`)
+
+var reGetMacroType = regexp.MustCompile(`##\s*(\w+)`)
+var reGetMacroArgs = regexp.MustCompile(`##\s*\w+\s+(.*)$`)
+var reBreakDownMacroArgs = regexp.MustCompile(`\S+`)
+
+// the first \w+ is the ## STRING text. The first capturing group gets the string name,
+// and the second capturing group gets the string contents
+var reGetMacroStringDeclarationArgs = regexp.MustCompile(`##\s*\w+\s+(\w+)\s+(.*)$`)
+
+var reIdentifiers = regexp.MustCompile(`\w+`)
+
+// blobDecoder is the function that decodes the strings made by blob.Pack, which is written into the code once
+const blobDecoder = `def ticc_unblob(s)
+  d, o, a, b, i = [], [], 0, 0, 0
+  s.bytes.each do |ch|
+    a = (a << 6) | (ch - 48)
+    b += 6
+    if b >= 8
+      b -= 8
+      d << (a >> b)
+      a &= (1 << b) - 1
+    end
+  end
+  while i < d.size
+    c = d[i]
+    i += 1
+    if c < 128
+      (c + 1).times do
+        o << d[i]
+        i += 1
+      end
+    else
+      (c - 125).times { o << d[i] }
+      i += 1
+    end
+  end
+  o
+end`
+
+// literalStyle describes how values are written as ruby arrays and hashes
+var literalStyle = literal.Style{
+	Nil:       "nil",
+	True:      "true",
+	False:     "false",
+	ListOpen:  "[",
+	ListClose: "]",
+	MapOpen:   "{",
+	MapClose:  "}",
+	Separator: ", ",
+	Key: func(key string) string {
+		return quoteString(key) + " => "
+	},
+	Quote: quoteString,
+}
+
+// quoteString escapes the '#' as well, since it starts an interpolation in double quoted ruby strings
+func quoteString(s string) string {
+	return literal.Escape(s, '"', "#")
+}
+
+// StripUnimportant returns a new line which is the result of stripping all the unimportant or non-usable
+// characters from it. This includes stripping away unneeded whitespace, comments, and any text that comes after comments.
+// The compiler uses StripBlocks instead, which knows about the comments and heredocs that span several lines.
+func (ls RubyLanguageService) StripUnimportant(line string) string {
+	stripped, _ := ls.StripBlocks(line, nil)
+	return stripped
+}
+
+// findCommentAndHeredoc returns the index of the '#' that starts a comment on the line, or -1 if there is none, along
+// with the index of the first heredoc that the code of the line starts, or -1 if there is none.
+// Unlike the other languages, the '#' is far too common inside ruby strings (like in "#{interpolation}")
+// to get away with a simple regex, so this keeps track of which string or interpolation we are in.
+func findCommentAndHeredoc(line string) (int, int) {
+	// each entry is the quote character of the string we are inside of, or '}' if we are
+	// inside a #{} interpolation within a double quoted string
+	var nesting []byte
+	heredocStart := -1
+
+	for i := 0; i < len(line); i++ {
+		char := line[i]
+
+		var current byte
+		if len(nesting) > 0 {
+			current = nesting[len(nesting)-1]
+		}
+
+		switch current {
+		case '"', '\'':
+			if char == '\\' {
+				i++
+			} else if char == current {
+				nesting = nesting[:len(nesting)-1]
+			} else if current == '"' && char == '#' && i+1 < len(line) && line[i+1] == '{' {
+				nesting = append(nesting, '}')
+				i++
+			}
+		default:
+			switch char {
+			case '#':
+				return i, heredocStart
+			case '"', '\'':
+				nesting = append(nesting, char)
+			case '<':
+				if heredocStart < 0 && reHeredoc.MatchString(line[i:]) {
+					heredocStart = i
+				}
+			case '{':
+				if current == '}' {
+					// a hash literal inside an interpolation, which needs its own closing brace
+					nesting = append(nesting, '{')
+				}
+			case '}':
+				if current == '}' || current == '{' {
+					nesting = nesting[:len(nesting)-1]
+				}
+			}
+		}
+	}
+
+	return -1, heredocStart
+}
+
+// IsLineImport determines whether a line of code contains an import statement. In ruby, this is 'require_relative'.
+func (ls RubyLanguageService) IsLineImport(line string) bool {
+	matched, _ := regexp.MatchString(`^\s*require_relative\b`, line)
+	return matched
+}
+
+// GetImportData will extract the relative path to the file that is being imported given a line of code with
+// an import statement. Ruby has no way of importing specific symbols, so there are never any symbols to validate.
+func (ls RubyLanguageService) GetImportData(line string) (compiler.ImportData, error) {
+	matchInfo := reRequireRelative.FindStringSubmatch(line)
+
+	if len(matchInfo) != 2 {
+		return compiler.ImportData{}, errors.New(`import line does not match the template: 'require_relative "{importpath}"'`)
+	}
+
+	return compiler.ImportData{
+		Path: strings.TrimSuffix(matchInfo[1], ".rb") + ".rb",
+	}, nil
+}
+
+// IsExportDeclaration determines if a line contains a top-level declaration that should be available to other
+// files importing this one
+func (ls RubyLanguageService) IsExportDeclaration(line string) bool {
+	// in ruby, the following are export declarations
+	// * class Entity
+	// * module Physics
+	// * def update
+	// * SPEED = 2
+	// * $player = ...
+	// and they must all have zero leading indentation
+
+	if reExportClassOrModule.MatchString(line) {
+		return true
+	}
+
+	if reExportMethod.MatchString(line) {
+		return true
+	}
+
+	if reExportAssignment.MatchString(line) {
+		return true
+	}
+
+	return false
+}
+
+// GetExportDeclarations extracts a list of exported symbols from the line
+func (ls RubyLanguageService) GetExportDeclarations(line string) []string {
+	matchInfo := reExportClassOrModule.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return matchInfo[1:2]
+	}
+
+	matchInfo = reExportMethod.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return matchInfo[1:2]
+	}
+
+	matchInfo = reExportAssignment.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return matchInfo[1:2]
+	}
+
+	return []string{}
+}
+
+// ContinuesDeclaration determines if a line continues the top level declaration on the lines before it, so that the
+// declaration can be left out as a whole when it is not used
+func (ls RubyLanguageService) ContinuesDeclaration(statement []string, line string) bool {
+	return reContinuesDeclaration.MatchString(line)
+}
+
+// ExtractPrelude extracts a string from the supplied main file code. This string is the prelude-
+// a set of comments that must appear at the top of a file used by the TIC-80 to determine the title,
+// author, description, language, and input type of the game.
+//
+// Normally, comments are stripped out by
+// StripUnimportant, which is why this needs to be its own separate method
+func (ls RubyLanguageService) ExtractPrelude(mainFileCode string) string {
+	result := ""
+	// split the code into lines
+	lines := strings.Split(mainFileCode, "\n")
+	for _, line := range lines {
+		if reIsPreludeComment.MatchString(line) {
+			result = result + line + "\n"
+		} else {
+			break
+		}
+	}
+	return result
+}
+
+// FormatLiteral writes a value built by the literal package as a ruby array, hash, string, or other literal
+func (ls RubyLanguageService) FormatLiteral(value interface{}) string {
+	return literal.Format(value, literalStyle)
+}
+
+// FormatDeclaration writes a top-level assignment that makes the value available to the whole program. Only
+// constants (names starting with a capital letter) can be seen from inside methods.
+func (ls RubyLanguageService) FormatDeclaration(name string, value string) string {
+	return name + " = " + value
+}
+
+// BlobDecoder writes the function that turns the strings made by blob.Pack back into bytes, as a top-level ruby method.
+func (ls RubyLanguageService) BlobDecoder() []string {
+	return strings.Split(blobDecoder, "\n")
+}
+
+// FormatBlobDecode writes a call to the function from BlobDecoder
+func (ls RubyLanguageService) FormatBlobDecode(packed string) string {
+	return "ticc_unblob(" + ls.FormatLiteral(packed) + ")"
+}
+
+// SubstituteDefines takes in a line of code and the current set of previously-declared defines. It then
+// detects any occurences of the defines that should be replaced and returns a string with these occurences
+// replaced by their correct definitions.
+func (ls RubyLanguageService) SubstituteDefines(line string, defines map[string]string) string {
+	return reIdentifiers.ReplaceAllStringFunc(line, func(identifier string) string {
+		replacement, isDefined := defines[identifier]
+		if !isDefined {
+			return identifier
+		}
+		return replacement
+	})
+}
+
+// IsLineMacro determines if the given line constitutes a macro declaration of some sort
+func (ls RubyLanguageService) IsLineMacro(line string) bool {
+	return reIsMacro.MatchString(line)
+}
+
+// GetMacroType will determine what type of macro a given line is, provided that it has been
+// detected previously by IsLineMacro.
+func (ls RubyLanguageService) GetMacroType(line string) compiler.MacroType {
+	matchInfo := reGetMacroType.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return compiler.MacroTypeUnknown
+	}
+
+	symbol := matchInfo[1]
+
+	switch strings.ToUpper(symbol) {
+	case "DEFINE":
+		return compiler.MacroTypeDefine
+	case "STRING":
+		return compiler.MacroTypeString
+	case "DATA":
+		return compiler.MacroTypeData
+	case "EMBED":
+		return compiler.MacroTypeEmbed
+	case "BLOB":
+		return compiler.MacroTypeBlob
+	case "IF":
+		return compiler.MacroTypeIf
+	case "ELSEIF":
+		return compiler.MacroTypeElseIf
+	case "ELSE":
+		return compiler.MacroTypeElse
+	case "ENDIF":
+		return compiler.MacroTypeEndIf
+	default:
+		return compiler.MacroTypeUnknown
+	}
+}
+
+// GetMacroArgs will return a slice of all the space-separated values that follow a
+// macro definition
+func (ls RubyLanguageService) GetMacroArgs(line string) []string {
+	matchInfo := reGetMacroArgs.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return []string{}
+	}
+
+	fullArgs := matchInfo[1]
+
+	return reBreakDownMacroArgs.FindAllString(fullArgs, -1)
+}
+
+// GetMacroStringDeclaration extracts the name and the contents of a string macro
+func (ls RubyLanguageService) GetMacroStringDeclaration(line string) (string, string, error) {
+	matchInfo := reGetMacroStringDeclarationArgs.FindStringSubmatch(line)
+
+	// the first string in matchInfo is always the full matched text
+	// the second string is the string name
+	// the third string is the string contents
+	if len(matchInfo) != 3 {
+		return "", "", errors.New("invalid format for string macro. must be ##string [STRING_NAME] STRING CONTENTS")
+	}
+
+	return matchInfo[1], matchInfo[2], nil
+}
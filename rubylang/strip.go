@@ -0,0 +1,57 @@
+package rubylang
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+// matches the line that starts a block comment, which has to be at the very start of the line
+var reBeginComment = regexp.MustCompile(`^=begin\b`)
+
+// matches the line that ends a block comment
+var reEndComment = regexp.MustCompile(`^=end\b`)
+
+// matches the start of a heredoc like <<~TEXT, <<-TEXT, <<TEXT or <<~'TEXT', capturing the word that ends it. A plain
+// << is only a heredoc when a capital letter or a quote follows it right away, so 'list << item' is still a shift.
+var reHeredoc = regexp.MustCompile(`^<<(?:[~-]["'` + "`" + `]?[A-Za-z_]|["'` + "`" + `]?[A-Z_])`)
+
+// extracts the word that ends a heredoc that starts at the beginning of the text
+var reHeredocWord = regexp.MustCompile(`^<<[~-]?["'` + "`" + `]?(\w+)`)
+
+// StripBlocks strips the comments and the trailing whitespace from a line like StripUnimportant, keeping track of the
+// =begin/=end comments and the heredocs that span several lines. The lines of a heredoc are kept exactly as they are,
+// including the one that ends it.
+func (ls RubyLanguageService) StripBlocks(line string, open *compiler.OpenBlock) (string, *compiler.OpenBlock) {
+	if open != nil {
+		if open.IsComment {
+			if reEndComment.MatchString(line) {
+				return "", nil
+			}
+			return "", open
+		}
+
+		if strings.TrimSpace(line) == open.Closing {
+			return line, nil
+		}
+		return line, open
+	}
+
+	if reBeginComment.MatchString(line) {
+		return "", &compiler.OpenBlock{Closing: "=end", IsComment: true}
+	}
+
+	commentStart, heredocStart := findCommentAndHeredoc(line)
+	if commentStart >= 0 {
+		line = line[:commentStart]
+	}
+	line = strings.TrimRight(line, " \t\n\r")
+
+	if heredocStart >= 0 {
+		word := reHeredocWord.FindStringSubmatch(line[heredocStart:])
+		return line, &compiler.OpenBlock{Closing: word[1]}
+	}
+
+	return line, nil
+}
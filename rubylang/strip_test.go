@@ -0,0 +1,61 @@
+package rubylang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func TestStripBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{
+			name:     "line comments",
+			lines:    []string{"x = 1 # one", "# only a comment", "  y = 2  "},
+			expected: []string{"x = 1", "", "  y = 2"},
+		},
+		{
+			name:     "hashes inside strings",
+			lines:    []string{`puts "#{a} # b" # comment`, `s = '#ff0000'`},
+			expected: []string{`puts "#{a} # b"`, `s = '#ff0000'`},
+		},
+		{
+			name:     "block comments",
+			lines:    []string{"=begin", "##define A 1", "SPEED = 2", "=end", "x = 1"},
+			expected: []string{"", "", "", "", "x = 1"},
+		},
+		{
+			name:     "heredocs",
+			lines:    []string{"HELP = <<~TEXT.strip # comment", "  Press Z  ", "  # not a comment", "  TEXT", "list << item"},
+			expected: []string{"HELP = <<~TEXT.strip", "  Press Z  ", "  # not a comment", "  TEXT", "list << item"},
+		},
+		{
+			name:     "heredocs with quotes",
+			lines:    []string{`s = <<'EOS'`, `#{not interpolated}`, `EOS`, `t = "<<~NOPE"`},
+			expected: []string{`s = <<'EOS'`, `#{not interpolated}`, `EOS`, `t = "<<~NOPE"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ls := RubyLanguageService{}
+			var open *compiler.OpenBlock
+
+			for i, line := range test.lines {
+				var stripped string
+				stripped, open = ls.StripBlocks(line, open)
+
+				if stripped != test.expected[i] {
+					t.Errorf("line %d: expected %q, got %q", i+1, test.expected[i], stripped)
+				}
+			}
+
+			if open != nil {
+				t.Errorf("expected everything to be closed, but %q is still open", open.Closing)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package squirrellang
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/literal"
+)
+
+// SquirrelLanguageService is a container struct that encapsulates a bunch of methods that
+// take in a line of code and do text processing to see if the line matches certain properties
+// based on the language this service provides.
+type SquirrelLanguageService struct {
+}
+
+// matches `local <symbol> = dofile("<path>")` and `<symbol> <- dofile("<path>")`
+var reImportExtract = regexp.MustCompile(`^\s*(?:local\s+)?(\w+)\s*(?:=|<-)\s*dofile\s*\(\s*"([\w\/\.]+)"[^)]*\)`)
+
+// matches a bare `dofile("<path>")` without assigning the result to anything
+var reBareImport = regexp.MustCompile(`dofile\s*\(\s*"([\w\/\.]+)"[^)]*\)`)
+
+// extracts an exported symbol of kind function [identifier]( or local function [identifier](
+var reExportFunction = regexp.MustCompile(`^(?:local\s+)?function\s+(\w+)\s*\(`)
+
+// extracts an exported symbol of kind class [identifier] or enum [identifier]
+var reExportClassOrEnum = regexp.MustCompile(`^(?:class|enum)\s+(\w+)`)
+
+// extracts an exported symbol of kind [identifier] <- ..., const [identifier] = ... or local [identifier] = ...
+var reExportSlot = regexp.MustCompile(`^(?:(\w+)\s*<-|(?:const|local)\s+(\w+)\s*=)`)
+
+// matches the lines that continue the top level statement before them, like indented lines and '}'
+var reContinuesDeclaration = regexp.MustCompile(`^(?:\s|[)\]}])`)
+
+var reIsPreludeComment = regexp.MustCompile(`^\/\/\s*\w+\s*:`)
+
+// detects a macro, which is //# followed by its name, so that a line like //#------------ stays a comment
+var reIsMacro = regexp.MustCompile(`^\s*\/\/#\s*\w`)
+
+var reGetMacroType = regexp.MustCompile(`\/\/#\s*(\w+)`)
+var reGetMacroArgs = regexp.MustCompile(`\/\/#\s*\w+\s+(.*)$`)
+var reBreakDownMacroArgs = regexp.MustCompile(`\S+`)
+
+var reGetMacroStringDeclarationArgs = regexp.MustCompile(`\/\/#\s*\w+\s+(\w+)\s+(.*)$`)
+
+var reIdentifiers = regexp.MustCompile(`\w+`)
+
+// blobDecoder is the function that decodes the strings made by blob.Pack, which is written into the code once
+const blobDecoder = `function ticc_unblob(s) {
+  local d = [], o = [], a = 0, b = 0, i = 0
+  foreach (ch in s) {
+    a = (a << 6) | (ch - 48)
+    b += 6
+    if (b >= 8) {
+      b -= 8
+      d.append(a >> b)
+      a = a & ((1 << b) - 1)
+    }
+  }
+  while (i < d.len()) {
+    local c = d[i++]
+    if (c < 128) {
+      for (local k = 0; k <= c; k++) o.append(d[i++])
+    } else {
+      for (local k = 0; k < c - 125; k++) o.append(d[i])
+      i++
+    }
+  }
+  return o
+}`
+
+// literalStyle describes how values are written as squirrel arrays and tables
+var literalStyle = literal.Style{
+	Nil:       "null",
+	True:      "true",
+	False:     "false",
+	ListOpen:  "[",
+	ListClose: "]",
+	MapOpen:   "{",
+	MapClose:  "}",
+	Separator: ", ",
+	Key: func(key string) string {
+		if literal.IsIdentifier(key) && !keywords[key] {
+			return key + " = "
+		}
+		return "[" + literal.Escape(key, '"', "") + "] = "
+	},
+	Quote: func(s string) string {
+		return literal.Escape(s, '"', "")
+	},
+}
+
+// keywords cannot be used as bare keys in a table
+var keywords = map[string]bool{
+	"base": true, "break": true, "case": true, "catch": true, "class": true, "clone": true, "const": true,
+	"constructor": true, "continue": true, "default": true, "delete": true, "else": true, "enum": true,
+	"extends": true, "false": true, "for": true, "foreach": true, "function": true, "if": true, "in": true,
+	"instanceof": true, "local": true, "null": true, "resume": true, "return": true, "static": true,
+	"switch": true, "this": true, "throw": true, "true": true, "try": true, "typeof": true, "while": true,
+	"yield": true,
+}
+
+// StripUnimportant returns a new line which is the result of stripping all the unimportant or non-usable
+// characters from it. This includes stripping away unneeded whitespace, comments, and any text that comes after comments.
+// The compiler uses StripBlocks instead, which knows about the comments and strings that span several lines.
+func (ls SquirrelLanguageService) StripUnimportant(line string) string {
+	stripped, _ := ls.StripBlocks(line, nil)
+	return stripped
+}
+
+// IsLineImport determines whether a line of code contains an import statement. In squirrel, this is a call
+// to 'dofile'.
+func (ls SquirrelLanguageService) IsLineImport(line string) bool {
+	matched, _ := regexp.MatchString(`\bdofile\b`, line)
+	return matched
+}
+
+// GetImportData will extract a slice of imported symbols and the relative path to the file that is being imported given
+// a line of code with an import statement
+func (ls SquirrelLanguageService) GetImportData(line string) (compiler.ImportData, error) {
+	matchInfo := reImportExtract.FindStringSubmatch(line)
+
+	if len(matchInfo) != 3 {
+		// this means the line does not match the template
+		// local <symbol> = dofile("<path>")
+
+		// check if the line is a bare dofile call
+		if bareImport := reBareImport.FindStringSubmatch(line); len(bareImport) > 0 {
+			return compiler.ImportData{
+				Path: dofilePathToFile(bareImport[1]),
+			}, nil
+		}
+
+		return compiler.ImportData{}, errors.New(`import line does not match the templates: 'local {symbol} = dofile("{path}")', '{symbol} <- dofile("{path}")' or 'dofile("{path}")'`)
+	}
+
+	importData := compiler.ImportData{
+		Symbols: matchInfo[1:2],
+		Path:    dofilePathToFile(matchInfo[2]),
+	}
+
+	return importData, nil
+}
+
+// dofilePathToFile makes sure the path passed to dofile has the .nut extension
+func dofilePathToFile(path string) string {
+	return strings.TrimSuffix(path, ".nut") + ".nut"
+}
+
+// IsExportDeclaration determines if a line contains a top-level declaration that should be available to other
+// files importing this one
+func (ls SquirrelLanguageService) IsExportDeclaration(line string) bool {
+	// in squirrel, the following are export declarations
+	// * function update() {
+	// * class Entity {
+	// * Player <- { ... }
+	// * const SPEED = 2
+	// * local world = ...
+	// and they must all have zero leading indentation. Because every file is stitched into the same script,
+	// a top-level local is still visible to the files that come after it.
+
+	if reExportFunction.MatchString(line) {
+		return true
+	}
+
+	if reExportClassOrEnum.MatchString(line) {
+		return true
+	}
+
+	if reExportSlot.MatchString(line) {
+		return true
+	}
+
+	return false
+}
+
+// GetExportDeclarations extracts a list of exported symbols from the line
+func (ls SquirrelLanguageService) GetExportDeclarations(line string) []string {
+	matchInfo := reExportFunction.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return matchInfo[1:2]
+	}
+
+	matchInfo = reExportClassOrEnum.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		return matchInfo[1:2]
+	}
+
+	matchInfo = reExportSlot.FindStringSubmatch(line)
+
+	if len(matchInfo) != 0 {
+		// only one of the two capturing groups will be filled in depending on the kind of declaration
+		if matchInfo[1] != "" {
+			return matchInfo[1:2]
+		}
+		return matchInfo[2:3]
+	}
+
+	return []string{}
+}
+
+// ContinuesDeclaration determines if a line continues the top level declaration on the lines before it, so that the
+// declaration can be left out as a whole when it is not used
+func (ls SquirrelLanguageService) ContinuesDeclaration(statement []string, line string) bool {
+	return reContinuesDeclaration.MatchString(line)
+}
+
+// ExtractPrelude extracts a string from the supplied main file code. This string is the prelude-
+// a set of comments that must appear at the top of a file used by the TIC-80 to determine the title,
+// author, description, language, and input type of the game.
+func (ls SquirrelLanguageService) ExtractPrelude(mainFileCode string) string {
+	result := ""
+
+	lines := strings.Split(mainFileCode, "\n")
+	for _, line := range lines {
+		if reIsPreludeComment.MatchString(line) {
+			result = result + line + "\n"
+		} else {
+			break
+		}
+	}
+
+	return result
+}
+
+// FormatLiteral writes a value built by the literal package as a squirrel array, table, string, or other literal
+func (ls SquirrelLanguageService) FormatLiteral(value interface{}) string {
+	return literal.Format(value, literalStyle)
+}
+
+// FormatDeclaration writes a new slot in the root table that makes the value available to the whole program
+func (ls SquirrelLanguageService) FormatDeclaration(name string, value string) string {
+	return name + " <- " + value
+}
+
+// BlobDecoder writes the function that turns the strings made by blob.Pack back into bytes, as a squirrel function.
+func (ls SquirrelLanguageService) BlobDecoder() []string {
+	return strings.Split(blobDecoder, "\n")
+}
+
+// FormatBlobDecode writes a call to the function from BlobDecoder
+func (ls SquirrelLanguageService) FormatBlobDecode(packed string) string {
+	return "ticc_unblob(" + ls.FormatLiteral(packed) + ")"
+}
+
+// SubstituteDefines takes in a line of code and the current set of previously-declared defines and replaces
+// any occurences of the defines with their definitions.
+func (ls SquirrelLanguageService) SubstituteDefines(line string, defines map[string]string) string {
+	return reIdentifiers.ReplaceAllStringFunc(line, func(identifier string) string {
+		replacement, isDefined := defines[identifier]
+		if !isDefined {
+			return identifier
+		}
+		return replacement
+	})
+}
+
+// IsLineMacro determines if the given line constitutes a macro declaration of some sort
+func (ls SquirrelLanguageService) IsLineMacro(line string) bool {
+	return reIsMacro.MatchString(line)
+}
+
+// GetMacroType will determine what type of macro a given line is, provided that it has been
+// detected previously by IsLineMacro.
+func (ls SquirrelLanguageService) GetMacroType(line string) compiler.MacroType {
+	matchInfo := reGetMacroType.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return compiler.MacroTypeUnknown
+	}
+
+	symbol := matchInfo[1]
+
+	switch strings.ToUpper(symbol) {
+	case "DEFINE":
+		return compiler.MacroTypeDefine
+	case "STRING":
+		return compiler.MacroTypeString
+	case "DATA":
+		return compiler.MacroTypeData
+	case "EMBED":
+		return compiler.MacroTypeEmbed
+	case "BLOB":
+		return compiler.MacroTypeBlob
+	case "IF":
+		return compiler.MacroTypeIf
+	case "ELSEIF":
+		return compiler.MacroTypeElseIf
+	case "ELSE":
+		return compiler.MacroTypeElse
+	case "ENDIF":
+		return compiler.MacroTypeEndIf
+	default:
+		return compiler.MacroTypeUnknown
+	}
+}
+
+// GetMacroArgs will return a slice of all the space-separated values that follow a
+// macro definition
+func (ls SquirrelLanguageService) GetMacroArgs(line string) []string {
+	matchInfo := reGetMacroArgs.FindStringSubmatch(line)
+
+	if len(matchInfo) < 2 {
+		return []string{}
+	}
+
+	fullArgs := matchInfo[1]
+
+	return reBreakDownMacroArgs.FindAllString(fullArgs, -1)
+}
+
+// GetMacroStringDeclaration extracts the name and the contents of a string macro
+func (ls SquirrelLanguageService) GetMacroStringDeclaration(line string) (string, string, error) {
+	matchInfo := reGetMacroStringDeclarationArgs.FindStringSubmatch(line)
+
+	// the first string in matchInfo is always the full matched text
+	// the second string is the string name
+	// the third string is the string contents
+	if len(matchInfo) != 3 {
+		return "", "", errors.New("invalid format for string macro. must be //#string [STRING_NAME] STRING CONTENTS")
+	}
+
+	return matchInfo[1], matchInfo[2], nil
+}
@@ -0,0 +1,118 @@
+package squirrellang
+
+import (
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+// StripBlocks strips the comments and the trailing whitespace from a line like StripUnimportant, keeping track of the
+// block comments (/* */) and verbatim strings (@"...") that span several lines. Quoted strings are skipped, so a //
+// inside of one (like in a url) is not mistaken for a comment.
+func (ls SquirrelLanguageService) StripBlocks(line string, open *compiler.OpenBlock) (string, *compiler.OpenBlock) {
+	var result strings.Builder
+	i := 0
+
+	for i < len(line) {
+		if open != nil {
+			end := _findClosing(line, i, open)
+
+			if end < 0 {
+				if !open.IsComment {
+					result.WriteString(line[i:])
+				}
+				// the verbatim string is kept exactly as it is, including the whitespace at the end of the line
+				return _trimIfCode(result.String(), open), open
+			}
+
+			if open.IsComment {
+				i = _skipCommentGap(line, end, &result)
+			} else {
+				result.WriteString(line[i:end])
+				i = end
+			}
+			open = nil
+			continue
+		}
+
+		char := line[i]
+
+		switch {
+		case strings.HasPrefix(line[i:], "//") || char == '#':
+			return strings.TrimRight(result.String(), " \t\n\r"), nil
+		case strings.HasPrefix(line[i:], "/*"):
+			open = &compiler.OpenBlock{Closing: "*/", IsComment: true}
+			i += 2
+		case strings.HasPrefix(line[i:], `@"`):
+			open = &compiler.OpenBlock{Closing: `"`}
+			result.WriteString(`@"`)
+			i += 2
+		case char == '"' || char == '\'':
+			end := _skipQuotedString(line, i)
+			result.WriteString(line[i:end])
+			i = end
+		default:
+			result.WriteByte(char)
+			i++
+		}
+	}
+
+	return _trimIfCode(result.String(), open), open
+}
+
+// _findClosing finds the position right after the text that closes the open comment or verbatim string, or -1 if it
+// is not closed on this line. Nothing is escaped inside of a verbatim string, except for a quote, which is doubled.
+func _findClosing(line string, start int, open *compiler.OpenBlock) int {
+	for i := start; i < len(line); i++ {
+		if !open.IsComment && strings.HasPrefix(line[i:], `""`) {
+			i++
+			continue
+		}
+		if strings.HasPrefix(line[i:], open.Closing) {
+			return i + len(open.Closing)
+		}
+	}
+	return -1
+}
+
+// _trimIfCode trims the whitespace at the end of a line, unless the line ends in the middle of a verbatim string
+func _trimIfCode(line string, open *compiler.OpenBlock) string {
+	if open != nil && !open.IsComment {
+		return line
+	}
+	return strings.TrimRight(line, " \t\n\r")
+}
+
+// _skipCommentGap finds where the code goes on after a block comment that ends in the middle of a line. The comment
+// keeps the code on either side of it apart with a space, but is left out entirely if nothing but whitespace comes
+// before it, so that the indentation of the line stays the same.
+func _skipCommentGap(line string, end int, result *strings.Builder) int {
+	if strings.TrimSpace(result.String()) == "" {
+		for end < len(line) && (line[end] == ' ' || line[end] == '\t') {
+			end++
+		}
+		return end
+	}
+
+	if !strings.HasSuffix(result.String(), " ") {
+		result.WriteByte(' ')
+	}
+	return end
+}
+
+// _skipQuotedString finds the position right after the quote that closes the string starting at the given position,
+// or the end of the line if the string is never closed. A backslash escapes the character after it.
+func _skipQuotedString(line string, start int) int {
+	quote := line[start]
+
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+
+	return len(line)
+}
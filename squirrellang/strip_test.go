@@ -0,0 +1,56 @@
+package squirrellang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func TestStripBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{
+			name:     "line comments",
+			lines:    []string{"local x = 1 // one", "// only a comment", "# another comment", "  y <- 2  "},
+			expected: []string{"local x = 1", "", "", "  y <- 2"},
+		},
+		{
+			name:     "slashes inside strings",
+			lines:    []string{`print("http://x.y", 0, 0) // site`, `local s = 'a', t = "\" // # "`},
+			expected: []string{`print("http://x.y", 0, 0)`, `local s = 'a', t = "\" // # "`},
+		},
+		{
+			name:     "block comments",
+			lines:    []string{"local x = 1 /* starts", "//#define A 1", "ends */ local y = 3", "a/* note */+ b"},
+			expected: []string{"local x = 1", "", "local y = 3", "a + b"},
+		},
+		{
+			name:     "verbatim strings",
+			lines:    []string{`local s = @"first  `, `// not a comment ""quoted""  `, "", `last" + t // comment`},
+			expected: []string{`local s = @"first  `, `// not a comment ""quoted""  `, "", `last" + t`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ls := SquirrelLanguageService{}
+			var open *compiler.OpenBlock
+
+			for i, line := range test.lines {
+				var stripped string
+				stripped, open = ls.StripBlocks(line, open)
+
+				if stripped != test.expected[i] {
+					t.Errorf("line %d: expected %q, got %q", i+1, test.expected[i], stripped)
+				}
+			}
+
+			if open != nil {
+				t.Errorf("expected everything to be closed, but %q is still open", open.Closing)
+			}
+		})
+	}
+}
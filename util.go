@@ -5,9 +5,23 @@ import (
 	"io/ioutil"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
+// matches a single line of a prelude like '-- title: game' or '// script: js', whatever the comment token is
+var rePreludeLine = regexp.MustCompile(`^\s*(?:--|//|;;|#)\s*(\w+)\s*:\s*(.*?)\s*$`)
+
+// finds out if a line is a comment, whatever the comment token is
+var reIsComment = regexp.MustCompile(`^\s*(?:--|//|;;|#)`)
+
+// A preludeEntry is one of the 'key: value' comments at the top of the code that the TIC-80 reads the
+// metadata of the cartridge from
+type preludeEntry struct {
+	key   string
+	value string
+}
+
 // checkError checks if the error exists, and if so, outputs it to stdout and panics
 func checkError(err error) {
 	if err != nil {
@@ -16,8 +30,8 @@ func checkError(err error) {
 	}
 }
 
-// findMainFile takes in a directory path and finds a file of any extension with the name 'main.*'
-// and returns the path to that file.
+// findMainFile takes in a directory path and finds a file with the name 'main.*' whose extension is one of the
+// supported languages (like main.lua, main.rb or main.nut) and returns the path to that file.
 // If multiple files exist that are called 'main', then it only returns the first one alphabetically by the extension
 func findMainFile(dirname string) (string, error) {
 	files, err := ioutil.ReadDir(dirname)
@@ -25,9 +39,36 @@ func findMainFile(dirname string) (string, error) {
 	for _, info := range files {
 		name := info.Name()
 		base := filepath.Base(name)
-		if strings.HasPrefix(base, "main.") {
+		if !strings.HasPrefix(base, "main.") {
+			continue
+		}
+		// files like main.tic or main.txt may also be lying around in the directory
+		ext := filepath.Ext(base)
+		if isSupportedLanguage(Language(strings.TrimPrefix(ext, "."))) {
 			return path.Join(dirname, base), nil
 		}
 	}
 	return "", errors.New("no main file found")
 }
+
+// readPrelude reads the 'key: value' comments at the top of the code of a cartridge, in whichever language it is in.
+// Other comments and blank lines may be mixed in, but the prelude ends at the first line of actual code.
+func readPrelude(code string) []preludeEntry {
+	entries := []preludeEntry{}
+
+	for _, line := range strings.Split(code, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if !reIsComment.MatchString(line) {
+			break
+		}
+
+		if matchInfo := rePreludeLine.FindStringSubmatch(line); matchInfo != nil {
+			entries = append(entries, preludeEntry{strings.ToLower(matchInfo[1]), matchInfo[2]})
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,209 @@
+package wrenlang
+
+import (
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+var keywords = map[string]bool{
+	"as": true, "break": true, "class": true, "construct": true, "continue": true, "else": true, "false": true,
+	"for": true, "foreign": true, "if": true, "import": true, "in": true, "is": true, "null": true, "return": true,
+	"static": true, "super": true, "this": true, "true": true, "var": true, "while": true,
+}
+
+// the classes of the core module, the class of the game that the TIC-80 creates, and the methods wren itself calls
+// when a class takes part in a for loop, an interpolation or a call
+var builtinNames = []string{
+	"Bool", "Class", "Fiber", "Fn", "List", "Map", "Null", "Num", "Object", "Range", "Sequence", "String", "System",
+	"Game", "new", "call", "toString", "iterate", "iteratorValue",
+}
+
+// the words that can come before the name of a method where it is defined
+var methodModifiers = map[string]bool{
+	"static": true, "construct": true, "foreign": true,
+}
+
+// Mangle renames the identifiers declared in the code to shorter ones. Since they are renamed everywhere they appear
+// no matter what scope they are in, only the names that are always variables are renamed: the ones declared by var,
+// a for loop, the parameters of a method or of a block, which are never called as methods (like in a.name) or used in
+// the interpolation of a string. Fields are left as they are.
+func (ls WrenLanguageService) Mangle(code string, reserved map[string]bool) (string, error) {
+	tokens, err := _splitTokens(code)
+
+	if err != nil {
+		return "", err
+	}
+
+	declared := make(map[string]bool)
+	excluded := make(map[string]bool)
+	taken := make(map[string]bool)
+
+	for name := range keywords {
+		taken[name] = true
+	}
+	// the new names can't shadow the reserved ones either, even where those are not used
+	for name := range reserved {
+		excluded[name], taken[name] = true, true
+	}
+	for _, name := range builtinNames {
+		excluded[name], taken[name] = true, true
+	}
+
+	line := []string{}
+	for _, t := range tokens {
+		switch {
+		case t.isCode:
+			line = append(line, t.text)
+			if _isWordChar(t.text[0]) {
+				taken[t.text] = true
+			}
+			if t.text[0] == '"' {
+				_findInterpolatedNames(t.text, excluded)
+			}
+		case t.text == "\n":
+			_findDeclarations(line, declared, excluded)
+			line = line[:0]
+		}
+	}
+	_findDeclarations(line, declared, excluded)
+
+	counts := make(map[string]int)
+	for _, t := range tokens {
+		if t.isCode && declared[t.text] && !excluded[t.text] {
+			counts[t.text]++
+		}
+	}
+
+	names := compiler.ShortNames(counts, taken)
+
+	var result strings.Builder
+	for _, t := range tokens {
+		if name, renamed := names[t.text]; renamed && t.isCode {
+			result.WriteString(name)
+		} else {
+			result.WriteString(t.text)
+		}
+	}
+
+	return result.String(), nil
+}
+
+// _findInterpolatedNames excludes every name inside the interpolations of a string from being renamed, since the
+// string is kept as it is
+func _findInterpolatedNames(text string, excluded map[string]bool) {
+	for i := strings.Index(text, "%("); i >= 0; i = strings.Index(text, "%(") {
+		end, err := _skipInterpolation(text, i+2)
+		if err != nil {
+			end = len(text)
+		}
+		for _, name := range reIdentifiers.FindAllString(text[i+2:end], -1) {
+			excluded[name] = true
+		}
+		text = text[end:]
+	}
+}
+
+// _findDeclarations finds the names that a line of code declares, and the ones it calls as methods
+func _findDeclarations(line []string, declared map[string]bool, excluded map[string]bool) {
+	for i, text := range line {
+		if !_isWordChar(text[0]) {
+			continue
+		}
+
+		previous := ""
+		if i > 0 {
+			previous = line[i-1]
+		}
+
+		switch {
+		case strings.HasPrefix(text, "_"):
+			// fields belong to the instances of a class rather than to a scope
+			excluded[text] = true
+		case previous == ".":
+			excluded[text] = true
+		case previous == "var":
+			declared[text] = true
+		case previous == "(" && i >= 2 && line[i-2] == "for":
+			declared[text] = true
+		}
+	}
+
+	// the parameters of blocks, as in {|a, b| a + b}
+	for i := 0; i+1 < len(line); i++ {
+		if line[i] == "{" && line[i+1] == "|" {
+			_declareNameList(line, i+2, "|", declared)
+		}
+	}
+
+	// the parameters of a method where it is defined, as in 'static update(dt) {', 'x=(value) {' or '[x, y] {'
+	i := 0
+	for i < len(line) && methodModifiers[line[i]] {
+		i++
+	}
+	if i < len(line) && line[i] != "(" && line[i] != "[" {
+		if keywords[line[i]] {
+			// like 'if (a) {'
+			return
+		}
+		// the name of the method, which subscript operators don't have
+		i++
+	}
+
+	parameters := []string{}
+	for i < len(line) {
+		switch line[i] {
+		case "=":
+			i++
+		case "(", "[":
+			closing := ")"
+			if line[i] == "[" {
+				closing = "]"
+			}
+			end := _findNameListEnd(line, i+1, closing)
+			if end < 0 {
+				return
+			}
+			for _, name := range line[i+1 : end] {
+				if name != "," {
+					parameters = append(parameters, name)
+				}
+			}
+			i = end + 1
+		case "{":
+			for _, name := range parameters {
+				declared[name] = true
+			}
+			return
+		default:
+			return
+		}
+	}
+}
+
+// _declareNameList declares the names of a comma separated list that starts at the given position and ends with the
+// given token, as long as it holds nothing but names
+func _declareNameList(line []string, start int, closing string, declared map[string]bool) {
+	end := _findNameListEnd(line, start, closing)
+
+	for i := start; i < end; i += 2 {
+		declared[line[i]] = true
+	}
+}
+
+// _findNameListEnd finds the position of the token that ends a comma separated list of names, or -1 if the list holds
+// anything other than names
+func _findNameListEnd(line []string, start int, closing string) int {
+	for i := start; i < len(line); i++ {
+		if line[i] == closing {
+			return i
+		}
+
+		isName := _isWordChar(line[i][0]) && (line[i][0] < '0' || line[i][0] > '9')
+		if (i-start)%2 == 0 && !isName || (i-start)%2 == 1 && line[i] != "," {
+			return -1
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,76 @@
+package wrenlang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func _reservedNames(names ...string) map[string]bool {
+	reserved := make(map[string]bool)
+	for _, name := range compiler.TICNames {
+		reserved[name] = true
+	}
+	for _, name := range names {
+		reserved[name] = true
+	}
+	return reserved
+}
+
+func TestMangle(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "variables",
+			input:    "var counter = 0\ncounter = counter + 1",
+			expected: "var a = 0\na = a + 1",
+		},
+		{
+			name:     "names of the TIC-80 and of wren that are shadowed",
+			input:    "var paint = 1\nvar fft = 2\nvar print = 3\nvar System = 4",
+			expected: "var paint = 1\nvar fft = 2\nvar print = 3\nvar System = 4",
+		},
+		{
+			name:     "reserved names",
+			input:    "var Player = 1\nvar speed = Player",
+			expected: "var Player = 1\nvar a = Player",
+		},
+		{
+			name:     "names used as methods and fields",
+			input:    "var width = 1\nvar height = 2\nthing.width = height\n_width = width",
+			expected: "var width = 1\nvar a = 2\nthing.width = a\n_width = width",
+		},
+		{
+			name:     "interpolation",
+			input:    "var total = 1\nvar other = 2\nSystem.print(\"%(total)\" + other.toString)",
+			expected: "var total = 1\nvar a = 2\nSystem.print(\"%(total)\" + a.toString)",
+		},
+		{
+			name:     "for loops",
+			input:    "for (index in 0..3) {\n  trace(index)\n}",
+			expected: "for (a in 0..3) {\n  trace(a)\n}",
+		},
+		{
+			name:     "parameters of methods and blocks",
+			input:    "class Thing {\n  scale(factor) { factor * 2 }\n}\nlist.each {|item| trace(item) }",
+			expected: "class Thing {\n  scale(a) { a * 2 }\n}\nlist.each {|b| trace(b) }",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mangled, err := WrenLanguageService{}.Mangle(test.input, _reservedNames("Player"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if mangled != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, mangled)
+			}
+		})
+	}
+}
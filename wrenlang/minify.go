@@ -0,0 +1,254 @@
+package wrenlang
+
+import (
+	"errors"
+	"strings"
+)
+
+// the tokens of more than one character that are made of punctuation, longest first so they are matched before their
+// prefixes
+var multiCharOperators = []string{
+	"...",
+	"..", "==", "!=", "<=", ">=", "&&", "||", "<<", ">>",
+}
+
+// the tokens that a line can't end on, so wren either skips the newline after them or fails to compile. The lines
+// after them can be joined onto them without changing anything.
+//
+// '{' and '|' are not among them, since a newline after them is what makes a block a list of statements rather than
+// a single expression.
+var continuingTokens = map[string]bool{
+	"(": true, "[": true, ",": true, ".": true, "=": true, "?": true, ":": true, "!": true, "~": true, "is": true,
+	"+": true, "-": true, "*": true, "/": true, "%": true, "<": true, ">": true, "&": true, "^": true,
+	"..": true, "...": true, "==": true, "!=": true, "<=": true, ">=": true, "&&": true, "||": true, "<<": true, ">>": true,
+}
+
+// Minify shrinks the code while keeping its meaning:
+//
+//   - all comments are dropped, including nested block comments
+//   - indentation and the spaces between tokens are removed wherever the tokens can't run together
+//   - blank lines are dropped, and lines are joined onto the ones before them when those end in a token that can't
+//     end a statement, like a comma or an operator
+//
+// Wren has no separator for statements other than the newline, so every other line break is kept.
+func (ls WrenLanguageService) Minify(code string) (string, error) {
+	tokens, err := _splitTokens(code)
+
+	if err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+
+	previous := ""
+	for _, t := range tokens {
+		if t.text == "\n" {
+			// drops blank lines and the line breaks that are ignored anyway
+			if previous != "" && previous != "\n" && !continuingTokens[previous] {
+				result.WriteString("\n")
+				previous = t.text
+			}
+			continue
+		}
+
+		if !t.isCode {
+			continue
+		}
+
+		if previous != "" && previous != "\n" && _needsSpace(previous, t.text) {
+			result.WriteByte(' ')
+		}
+
+		result.WriteString(t.text)
+		previous = t.text
+	}
+
+	if previous != "" && previous != "\n" {
+		result.WriteString("\n")
+	}
+
+	return result.String(), nil
+}
+
+// a piece of the code, which is either a token of the code itself or whitespace, a line break or a comment
+type token struct {
+	text   string
+	isCode bool
+}
+
+// _splitTokens splits the code into tokens, which make up the code again when they are put back together. Every line
+// break is a token of its own, except the ones inside strings and block comments.
+func _splitTokens(code string) ([]token, error) {
+	tokens := []token{}
+
+	for i := 0; i < len(code); {
+		char := code[i]
+		end := i + 1
+		isCode := true
+
+		switch {
+		case char == '\n':
+			isCode = false
+		case char == ' ' || char == '\t' || char == '\r':
+			for end < len(code) && (code[end] == ' ' || code[end] == '\t' || code[end] == '\r') {
+				end++
+			}
+			isCode = false
+		case strings.HasPrefix(code[i:], "//"):
+			for end < len(code) && code[end] != '\n' {
+				end++
+			}
+			isCode = false
+		case strings.HasPrefix(code[i:], "/*"):
+			var err error
+			if end, err = _skipBlockComment(code, i); err != nil {
+				return nil, err
+			}
+			isCode = false
+		case strings.HasPrefix(code[i:], `"""`):
+			closing := strings.Index(code[i+3:], `"""`)
+			if closing < 0 {
+				return nil, errors.New("unterminated raw string")
+			}
+			end = closing + i + 6
+		case char == '"':
+			var err error
+			if end, err = _skipString(code, i); err != nil {
+				return nil, err
+			}
+		case _isWordChar(char):
+			for end < len(code) && (_isWordChar(code[end]) || _isNumberContinuation(code, i, end)) {
+				end++
+			}
+		default:
+			for _, operator := range multiCharOperators {
+				if strings.HasPrefix(code[i:], operator) {
+					end = i + len(operator)
+					break
+				}
+			}
+		}
+
+		tokens = append(tokens, token{code[i:end], isCode})
+		i = end
+	}
+
+	return tokens, nil
+}
+
+// _skipBlockComment finds the position right after the end of the block comment that starts at the given position.
+// Block comments nest in wren, so /* /* */ */ is a single comment.
+func _skipBlockComment(code string, start int) (int, error) {
+	depth := 0
+
+	for i := start; i < len(code); i++ {
+		switch {
+		case strings.HasPrefix(code[i:], "/*"):
+			depth++
+			i++
+		case strings.HasPrefix(code[i:], "*/"):
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+
+	return 0, errors.New("unterminated block comment")
+}
+
+// _skipString finds the position right after the closing quote of the string that starts at the given position. The
+// code inside an interpolation like %(a + b) can hold strings and parentheses of its own.
+func _skipString(code string, start int) (int, error) {
+	for i := start + 1; i < len(code); i++ {
+		switch {
+		case code[i] == '\\':
+			// the escaped character can't close the string
+			i++
+		case code[i] == '"':
+			return i + 1, nil
+		case strings.HasPrefix(code[i:], "%("):
+			end, err := _skipInterpolation(code, i+2)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		}
+	}
+
+	return 0, errors.New("unterminated string")
+}
+
+// _skipInterpolation finds the position right after the parenthesis that closes an interpolation
+func _skipInterpolation(code string, start int) (int, error) {
+	depth := 1
+
+	for i := start; i < len(code); i++ {
+		switch code[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		case '"':
+			end, err := _skipString(code, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		}
+	}
+
+	return 0, errors.New("unterminated interpolation in a string")
+}
+
+// _isNumberContinuation determines if the character at end continues the number that starts at start, like the
+// decimal point of 1.5 or the sign of the exponent in 1e-5
+func _isNumberContinuation(code string, start int, end int) bool {
+	if code[start] < '0' || code[start] > '9' {
+		return false
+	}
+
+	switch code[end] {
+	case '.':
+		// 1..2 is a range and 1.abs is a method call, so only a digit makes it a decimal point
+		return end+1 < len(code) && code[end+1] >= '0' && code[end+1] <= '9'
+	case '-', '+':
+		previous := code[end-1]
+		isHex := len(code) > start+1 && (code[start+1] == 'x' || code[start+1] == 'X')
+		return !isHex && (previous == 'e' || previous == 'E')
+	}
+
+	return false
+}
+
+// _needsSpace determines if two tokens would run together into something else without a space between them
+func _needsSpace(previous string, next string) bool {
+	lastChar := previous[len(previous)-1]
+	firstChar := next[0]
+
+	// two words would become one, and a number and a word could become a longer number
+	if _isWordChar(lastChar) && _isWordChar(firstChar) {
+		return true
+	}
+
+	// the two tokens would read as a comment or a longer operator
+	joined := string(lastChar) + string(firstChar)
+	if joined == "//" || joined == "/*" {
+		return true
+	}
+	for _, operator := range multiCharOperators {
+		if strings.HasPrefix(operator, joined) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func _isWordChar(char byte) bool {
+	return char == '_' || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || char >= 0x80
+}
@@ -0,0 +1,281 @@
+package wrenlang
+
+import (
+	"strings"
+	"testing"
+)
+
+// the operators of more than one character, longest first so they are matched before their prefixes
+var lexOperators = []string{"...", "..", "==", "!=", "<=", ">=", "&&", "||", "<<", ">>"}
+
+// _lexTokens splits the code into the tokens that wren reads, leaving out whitespace, comments and line breaks. It is
+// kept apart from the lexer of the minifier, so that the tests don't just check the minifier against itself.
+func _lexTokens(t *testing.T, code string) []string {
+	tokens := []string{}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		end := i + 1
+
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(rest[0])):
+			i++
+			continue
+		case strings.HasPrefix(rest, "//"):
+			for end < len(code) && code[end] != '\n' {
+				end++
+			}
+			i = end
+			continue
+		case strings.HasPrefix(rest, "/*"):
+			i = _lexComment(t, code, i)
+			continue
+		case strings.HasPrefix(rest, `"""`):
+			closing := strings.Index(rest[3:], `"""`)
+			if closing < 0 {
+				t.Fatalf("unterminated raw string in %q", code)
+			}
+			end = i + closing + 6
+		case rest[0] == '"':
+			end = _lexString(t, code, i)
+		case rest[0] >= '0' && rest[0] <= '9':
+			end = _lexNumber(code, i)
+		case _isLexWordChar(rest[0]):
+			for end < len(code) && _isLexWordChar(code[end]) {
+				end++
+			}
+		default:
+			for _, operator := range lexOperators {
+				if strings.HasPrefix(rest, operator) {
+					end = i + len(operator)
+					break
+				}
+			}
+		}
+
+		tokens = append(tokens, code[i:end])
+		i = end
+	}
+
+	return tokens
+}
+
+// _lexComment finds the position right after the end of the block comment starting at the given position, going
+// through the comments nested inside of it
+func _lexComment(t *testing.T, code string, start int) int {
+	depth := 0
+
+	for i := start; i < len(code); i++ {
+		if strings.HasPrefix(code[i:], "/*") {
+			depth++
+			i++
+		} else if strings.HasPrefix(code[i:], "*/") {
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	t.Fatalf("unterminated comment in %q", code)
+	return 0
+}
+
+// _lexNumber finds the position right after the number starting at the given position. A dot is only part of the
+// number when a digit follows it, and a sign only right after the e of an exponent.
+func _lexNumber(code string, start int) int {
+	isHex := strings.HasPrefix(code[start:], "0x")
+	isDigit := func(at int) bool { return at < len(code) && code[at] >= '0' && code[at] <= '9' }
+
+	end := start + 1
+	for end < len(code) {
+		char := code[end]
+
+		switch {
+		case _isLexWordChar(char), char == '.' && isDigit(end+1):
+			end++
+		case (char == '-' || char == '+') && !isHex && (code[end-1] == 'e' || code[end-1] == 'E'):
+			end++
+		default:
+			return end
+		}
+	}
+
+	return end
+}
+
+// _lexString finds the position right after the quote that closes the string starting at the given position, going
+// through the strings inside of its interpolations
+func _lexString(t *testing.T, code string, start int) int {
+	for i := start + 1; i < len(code); i++ {
+		switch {
+		case code[i] == '\\':
+			i++
+		case code[i] == '"':
+			return i + 1
+		case strings.HasPrefix(code[i:], "%("):
+			depth := 1
+			for i += 2; depth > 0; i++ {
+				if i >= len(code) {
+					t.Fatalf("unterminated interpolation in %q", code)
+				}
+				switch code[i] {
+				case '"':
+					i = _lexString(t, code, i) - 1
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+			}
+			i--
+		}
+	}
+
+	t.Fatalf("unterminated string in %q", code)
+	return 0
+}
+
+func _isLexWordChar(char byte) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+}
+
+func _minify(t *testing.T, code string) string {
+	minified, err := WrenLanguageService{}.Minify(code)
+	if err != nil {
+		t.Fatalf("could not minify %q: %v", code, err)
+	}
+	return minified
+}
+
+func TestMinify(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		// the tokens of both the input and the output, which the minifier must not change
+		tokens []string
+	}{
+		{
+			name:     "nested block comments",
+			input:    "var a = 1 /* outer /* inner */ still a comment */ + 2",
+			expected: "var a=1+2\n",
+			tokens:   []string{"var", "a", "=", "1", "+", "2"},
+		},
+		{
+			name:     "line comments",
+			input:    "var a = 1 // the first\n// on its own\nvar b = 2",
+			expected: "var a=1\nvar b=2\n",
+			tokens:   []string{"var", "a", "=", "1", "var", "b", "=", "2"},
+		},
+		{
+			name:     "raw strings",
+			input:    "var s = \"\"\"\n  raw /* not a comment */ \"quoted\"\n\"\"\"",
+			expected: "var s=\"\"\"\n  raw /* not a comment */ \"quoted\"\n\"\"\"\n",
+			tokens:   []string{"var", "s", "=", "\"\"\"\n  raw /* not a comment */ \"quoted\"\n\"\"\""},
+		},
+		{
+			name:     "raw strings with a line comment and a quote",
+			input:    "var s = \"\"\"// kept \"\"\" + \"a\"",
+			expected: "var s=\"\"\"// kept \"\"\"+\"a\"\n",
+			tokens:   []string{"var", "s", "=", `"""// kept """`, "+", `"a"`},
+		},
+		{
+			name:     "interpolation",
+			input:    `System.print("a %(f("x)", (1 + 2))) b")`,
+			expected: `System.print("a %(f("x)", (1 + 2))) b")` + "\n",
+			tokens:   []string{"System", ".", "print", "(", `"a %(f("x)", (1 + 2))) b"`, ")"},
+		},
+		{
+			name:     "ranges",
+			input:    "for (i in 1 .. 2) {\n  x = i\n}",
+			expected: "for(i in 1..2){\nx=i\n}\n",
+			tokens:   []string{"for", "(", "i", "in", "1", "..", "2", ")", "{", "x", "=", "i", "}"},
+		},
+		{
+			name:     "ranges and decimals without spaces",
+			input:    "var r = 1..2\nvar s = 1...3\nvar d = 1.5",
+			expected: "var r=1..2\nvar s=1...3\nvar d=1.5\n",
+			tokens:   []string{"var", "r", "=", "1", "..", "2", "var", "s", "=", "1", "...", "3", "var", "d", "=", "1.5"},
+		},
+		{
+			name:     "methods called on numbers",
+			input:    "var a = 1.abs + 2 . sqrt",
+			expected: "var a=1.abs+2.sqrt\n",
+			tokens:   []string{"var", "a", "=", "1", ".", "abs", "+", "2", ".", "sqrt"},
+		},
+		{
+			name:     "exponents",
+			input:    "var a = 1e-5 - 2",
+			expected: "var a=1e-5-2\n",
+			tokens:   []string{"var", "a", "=", "1e-5", "-", "2"},
+		},
+		{
+			name:     "newline after a brace is kept",
+			input:    "if (a) {\n  b()\n}",
+			expected: "if(a){\nb()\n}\n",
+			tokens:   []string{"if", "(", "a", ")", "{", "b", "(", ")", "}"},
+		},
+		{
+			name:     "newline after the parameters of a block is kept",
+			input:    "list.each {|x|\n  System.print(x)\n}",
+			expected: "list.each{|x|\nSystem.print(x)\n}\n",
+			tokens:   []string{"list", ".", "each", "{", "|", "x", "|", "System", ".", "print", "(", "x", ")", "}"},
+		},
+		{
+			name:     "newline after a comma is joined",
+			input:    "var list = [\n  1,\n  2\n]",
+			expected: "var list=[1,2\n]\n",
+			tokens:   []string{"var", "list", "=", "[", "1", ",", "2", "]"},
+		},
+		{
+			name:     "newline after an assignment is joined",
+			input:    "var a =\n  3",
+			expected: "var a=3\n",
+			tokens:   []string{"var", "a", "=", "3"},
+		},
+		{
+			name:     "newline after an operator is joined",
+			input:    "var a = b +\n  c &&\n  d",
+			expected: "var a=b+c&&d\n",
+			tokens:   []string{"var", "a", "=", "b", "+", "c", "&&", "d"},
+		},
+		{
+			name:     "blank lines are dropped",
+			input:    "var a = 1\n\n\n  \nvar b = 2\n",
+			expected: "var a=1\nvar b=2\n",
+			tokens:   []string{"var", "a", "=", "1", "var", "b", "=", "2"},
+		},
+		{
+			name:     "words are kept apart",
+			input:    "if (a is Num) return a",
+			expected: "if(a is Num)return a\n",
+			tokens:   []string{"if", "(", "a", "is", "Num", ")", "return", "a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := _minify(t, test.input)
+
+			if output != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, output)
+			}
+
+			expected := strings.Join(test.tokens, "\x00")
+			if input := _lexTokens(t, test.input); strings.Join(input, "\x00") != expected {
+				t.Errorf("expected the input to have the tokens %q, got %q", test.tokens, input)
+			}
+			if minified := _lexTokens(t, output); strings.Join(minified, "\x00") != expected {
+				t.Errorf("the tokens changed from %q to %q", test.tokens, minified)
+			}
+		})
+	}
+}
+
+func TestMinifyUnterminatedComment(t *testing.T) {
+	if _, err := (WrenLanguageService{}).Minify("var a = 1 /* never closed"); err == nil {
+		t.Error("expected an error for a block comment that is never closed")
+	}
+}
@@ -6,14 +6,12 @@ import (
 	"strings"
 
 	"github.com/novemberisms/ticc/compiler"
+	"github.com/novemberisms/ticc/literal"
 )
 
 type WrenLanguageService struct {
 }
 
-// finds the start of a single line comment
-var reSingleLineComment = regexp.MustCompile(`\/\/.*`)
-
 // matches `import "<path>" for <symbols>`
 var reImportExtract = regexp.MustCompile(`import\s+"([\w\/]+)"\s+for\s+(.+)`)
 
@@ -29,6 +27,9 @@ var reExportVarDeclaration = regexp.MustCompile(`var\s+([A-Z]\w*)`)
 
 var reIsPreludeComment = regexp.MustCompile(`^\/\/\s*\w+\s*:`)
 
+// detects a macro, which is //# followed by its name, so that a line like //#------------ stays a comment
+var reIsMacro = regexp.MustCompile(`^\s*\/\/#\s*\w`)
+
 var reGetMacroType = regexp.MustCompile(`\/\/#\s*(\w+)`)
 var reGetMacroArgs = regexp.MustCompile(`\/\/#\s*\w+\s+(.*)$`)
 var reBreakDownMacroArgs = regexp.MustCompile(`\S+`)
@@ -37,10 +38,64 @@ var reGetMacroStringDeclarationArgs = regexp.MustCompile(`\/\/#\s*\w+\s+(\w+)\s+
 
 var reIdentifiers = regexp.MustCompile(`\w+`)
 
+// blobDecoder is the function that decodes the strings made by blob.Pack, which is written into the code once
+const blobDecoder = `class TiccBlob {
+  static decode(s) {
+    var d = []
+    var o = []
+    var a = 0
+    var b = 0
+    for (c in s.bytes) {
+      a = (a << 6) | (c - 48)
+      b = b + 6
+      if (b >= 8) {
+        b = b - 8
+        d.add(a >> b)
+        a = a & ((1 << b) - 1)
+      }
+    }
+    var i = 0
+    while (i < d.count) {
+      var c = d[i]
+      i = i + 1
+      if (c < 128) {
+        for (k in 0..c) {
+          o.add(d[i])
+          i = i + 1
+        }
+      } else {
+        for (k in 1..(c - 125)) o.add(d[i])
+        i = i + 1
+      }
+    }
+    return o
+  }
+}`
+
+// literalStyle describes how values are written as wren lists and maps
+var literalStyle = literal.Style{
+	Nil:       "null",
+	True:      "true",
+	False:     "false",
+	ListOpen:  "[",
+	ListClose: "]",
+	MapOpen:   "{",
+	MapClose:  "}",
+	Separator: ", ",
+	Key: func(key string) string {
+		return quoteString(key) + ": "
+	},
+	Quote: quoteString,
+}
+
+// quoteString escapes the '%' as well, since it starts an interpolation in wren strings
+func quoteString(s string) string {
+	return literal.Escape(s, '"', "%")
+}
+
 func (ls WrenLanguageService) StripUnimportant(line string) string {
-	withoutComments := reSingleLineComment.ReplaceAllString(line, "")
-	trimmed := strings.TrimSpace(withoutComments)
-	return trimmed
+	stripped, _ := ls.StripBlocks(line, nil)
+	return stripped
 }
 
 func (ls WrenLanguageService) IsLineImport(line string) bool {
@@ -112,6 +167,36 @@ func (ls WrenLanguageService) GetExportDeclarations(line string) []string {
 	return []string{}
 }
 
+// ContinuesDeclaration determines if a line continues the top level statement made of the lines before it, so that
+// the declaration can be left out as a whole when it is not used. Since the indentation is stripped, a statement goes
+// on for as long as it has a bracket, string or block comment that is not closed yet, or ends in a token that can't end
+// a line, like a comma or an operator.
+func (ls WrenLanguageService) ContinuesDeclaration(statement []string, line string) bool {
+	tokens, err := _splitTokens(strings.Join(statement, "\n"))
+
+	if err != nil {
+		// a string or a block comment that is still open
+		return true
+	}
+
+	depth := 0
+	last := ""
+	for _, t := range tokens {
+		if !t.isCode {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		}
+		last = t.text
+	}
+
+	return depth > 0 || continuingTokens[last]
+}
+
 func (ls WrenLanguageService) ExtractPrelude(mainFileCode string) string {
 	result := ""
 
@@ -127,6 +212,27 @@ func (ls WrenLanguageService) ExtractPrelude(mainFileCode string) string {
 	return result
 }
 
+// FormatLiteral writes a value built by the literal package as a wren list, map, string, or other literal
+func (ls WrenLanguageService) FormatLiteral(value interface{}) string {
+	return literal.Format(value, literalStyle)
+}
+
+// FormatDeclaration writes a top-level variable declaration that makes the value available to the whole program
+func (ls WrenLanguageService) FormatDeclaration(name string, value string) string {
+	return "var " + name + " = " + value
+}
+
+// BlobDecoder writes the function that turns the strings made by blob.Pack back into bytes, as a wren class with a
+// static method, since wren has no top-level functions.
+func (ls WrenLanguageService) BlobDecoder() []string {
+	return strings.Split(blobDecoder, "\n")
+}
+
+// FormatBlobDecode writes a call to the function from BlobDecoder
+func (ls WrenLanguageService) FormatBlobDecode(packed string) string {
+	return "TiccBlob.decode(" + ls.FormatLiteral(packed) + ")"
+}
+
 func (ls WrenLanguageService) SubstituteDefines(line string, defines map[string]string) string {
 	return reIdentifiers.ReplaceAllStringFunc(line, func(identifier string) string {
 		replacement, isDefined := defines[identifier]
@@ -138,13 +244,16 @@ func (ls WrenLanguageService) SubstituteDefines(line string, defines map[string]
 }
 
 func (ls WrenLanguageService) IsLineMacro(line string) bool {
-	matches, _ := regexp.MatchString(`^\s*\/\/#`, line)
-	return matches
+	return reIsMacro.MatchString(line)
 }
 
 func (ls WrenLanguageService) GetMacroType(line string) compiler.MacroType {
 	matchInfo := reGetMacroType.FindStringSubmatch(line)
 
+	if len(matchInfo) < 2 {
+		return compiler.MacroTypeUnknown
+	}
+
 	symbol := matchInfo[1]
 
 	switch strings.ToUpper(symbol) {
@@ -152,6 +261,12 @@ func (ls WrenLanguageService) GetMacroType(line string) compiler.MacroType {
 		return compiler.MacroTypeDefine
 	case "STRING":
 		return compiler.MacroTypeString
+	case "DATA":
+		return compiler.MacroTypeData
+	case "EMBED":
+		return compiler.MacroTypeEmbed
+	case "BLOB":
+		return compiler.MacroTypeBlob
 	case "IF":
 		return compiler.MacroTypeIf
 	case "ELSEIF":
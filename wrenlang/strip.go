@@ -0,0 +1,112 @@
+package wrenlang
+
+import (
+	"strings"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+// StripBlocks strips the comments and the whitespace around a line like StripUnimportant, keeping track of the block
+// comments (/* */) and strings that span several lines. Block comments nest in wren, and a // inside of a string is
+// not mistaken for a comment.
+func (ls WrenLanguageService) StripBlocks(line string, open *compiler.OpenBlock) (string, *compiler.OpenBlock) {
+	var result strings.Builder
+	// the text of a string that carries on from the line before is kept as it is, including its indentation
+	startsInString := open != nil && !open.IsComment
+	i := 0
+
+	for i < len(line) {
+		if open != nil {
+			end, depth := _findClosing(line, i, open)
+
+			if end < 0 {
+				if open.IsComment {
+					open = &compiler.OpenBlock{Closing: open.Closing, IsComment: true, Depth: depth}
+				} else {
+					result.WriteString(line[i:])
+				}
+				return _trim(result.String(), startsInString, open), open
+			}
+
+			if open.IsComment {
+				// the comment keeps the code on either side of it apart
+				if !strings.HasSuffix(result.String(), " ") {
+					result.WriteByte(' ')
+				}
+			} else {
+				result.WriteString(line[i:end])
+			}
+			i = end
+			open = nil
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line[i:], "//"):
+			return _trim(result.String(), startsInString, nil), nil
+		case strings.HasPrefix(line[i:], "/*"):
+			open = &compiler.OpenBlock{Closing: "*/", IsComment: true, Depth: 1}
+			i += 2
+		case strings.HasPrefix(line[i:], `"""`):
+			open = &compiler.OpenBlock{Closing: `"""`}
+			result.WriteString(`"""`)
+			i += 3
+		case line[i] == '"':
+			open = &compiler.OpenBlock{Closing: `"`}
+			result.WriteByte('"')
+			i++
+		default:
+			result.WriteByte(line[i])
+			i++
+		}
+	}
+
+	return _trim(result.String(), startsInString, open), open
+}
+
+// _findClosing finds the position right after the text that closes the open comment or string, or -1 if it is not
+// closed on this line. For a comment, the number of comments still open at the end of the line is returned as well.
+func _findClosing(line string, start int, open *compiler.OpenBlock) (int, int) {
+	switch {
+	case open.IsComment:
+		depth := open.Depth
+		for i := start; i < len(line); i++ {
+			switch {
+			case strings.HasPrefix(line[i:], "/*"):
+				depth++
+				i++
+			case strings.HasPrefix(line[i:], "*/"):
+				depth--
+				i++
+				if depth == 0 {
+					return i + 1, 0
+				}
+			}
+		}
+		return -1, depth
+	case open.Closing == `"""`:
+		// nothing is escaped inside of a raw string
+		if end := strings.Index(line[start:], `"""`); end >= 0 {
+			return start + end + 3, 0
+		}
+		return -1, 0
+	default:
+		// the quote in front stands in for the one that opened the string on an earlier line
+		end, err := _skipString(`"`+line[start:], 0)
+		if err != nil {
+			return -1, 0
+		}
+		return start + end - 1, 0
+	}
+}
+
+// _trim trims the whitespace around a line, except where the line starts or ends in the middle of a string
+func _trim(line string, startsInString bool, open *compiler.OpenBlock) string {
+	if !startsInString {
+		line = strings.TrimLeft(line, " \t\n\r")
+	}
+	if open == nil || open.IsComment {
+		line = strings.TrimRight(line, " \t\n\r")
+	}
+	return line
+}
@@ -0,0 +1,61 @@
+package wrenlang
+
+import (
+	"testing"
+
+	"github.com/novemberisms/ticc/compiler"
+)
+
+func TestStripBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{
+			name:     "line comments",
+			lines:    []string{"var x = 1 // one", "// only a comment", "  y = 2  "},
+			expected: []string{"var x = 1", "", "y = 2"},
+		},
+		{
+			name:     "slashes inside strings",
+			lines:    []string{`var url = "http://tic80.com" // site`, `var s = "a \" // %("b // c") d"`},
+			expected: []string{`var url = "http://tic80.com"`, `var s = "a \" // %("b // c") d"`},
+		},
+		{
+			name:     "nested block comments",
+			lines:    []string{"var x = 1 /* outer", "/* inner */ still", "a comment */ var y = 2", "a/* note */+ b"},
+			expected: []string{"var x = 1", "", "var y = 2", "a + b"},
+		},
+		{
+			name:     "raw strings",
+			lines:    []string{`var s = """  `, `  // not a comment "quoted"`, "", `""" + t // comment`},
+			expected: []string{`var s = """  `, `  // not a comment "quoted"`, "", `""" + t`},
+		},
+		{
+			name:     "quoted strings over several lines",
+			lines:    []string{`var s = "first`, `  second \" // still`, `  third" // comment`},
+			expected: []string{`var s = "first`, `  second \" // still`, `  third"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ls := WrenLanguageService{}
+			var open *compiler.OpenBlock
+
+			for i, line := range test.lines {
+				var stripped string
+				stripped, open = ls.StripBlocks(line, open)
+
+				if stripped != test.expected[i] {
+					t.Errorf("line %d: expected %q, got %q", i+1, test.expected[i], stripped)
+				}
+			}
+
+			if open != nil {
+				t.Errorf("expected everything to be closed, but %q is still open", open.Closing)
+			}
+		})
+	}
+}